@@ -13,8 +13,11 @@ import (
 	"github.com/elect0/chimera/internal/adapters/cache"
 	"github.com/elect0/chimera/internal/adapters/storage"
 	"github.com/elect0/chimera/internal/application/transformation"
+	"github.com/elect0/chimera/internal/circuitbreaker"
 	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/health"
 	"github.com/elect0/chimera/internal/logger"
+	"github.com/elect0/chimera/internal/ports"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -37,6 +40,10 @@ func serveMetrics(log *slog.Logger) {
 func main() {
 	cfg := config.New()
 
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		os.Exit(runPurge(cfg, os.Args[2:]))
+	}
+
 	log := logger.New(cfg.Log.Level)
 	log.Info("logger initialized", slog.String("level", cfg.Log.Level))
 	fmt.Println(`
@@ -54,26 +61,120 @@ func main() {
                                                                                                   
 		`)
 
-	s3OriginRepo, err := storage.NewS3OriginRepository(context.Background(), cfg, log)
+	origins, err := storage.NewOrigins(context.Background(), cfg, log)
 	if err != nil {
-		log.Error("failed to create S3 origin repository", slog.String("error", err.Error()))
+		log.Error("failed to create origin repositories", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
-	log.Info("S3 origin repository initialized")
+	log.Info("origin repositories initialized", slog.Int("origins", len(origins)))
 
-	httpOriginRepo := storage.NewHTTPOriginRepository(cfg, log)
-	log.Info("HTTP origin repository initialized")
+	checks := []health.Check{
+		{Name: "libvips", Run: transformation.CheckVips},
+	}
+	for name, origin := range origins {
+		if pinger, ok := origin.(interface{ Ping(context.Context) error }); ok {
+			checks = append(checks, health.Check{Name: "origin_" + name, Run: pinger.Ping})
+		}
+	}
 
-	cacheRepo, err := cache.NewRedisCacheRepository(context.Background(), cfg, log)
-	if err != nil {
-		log.Error("failed to create redis cache repository", slog.String("error", err.Error()))
-		os.Exit(1)
+	if cfg.Cache.Source.Enabled {
+		var sourceStore ports.CacheRepository
+		capacity := int64(cfg.Cache.Source.CapacityMB) * 1024 * 1024
+
+		switch cfg.Cache.Source.Backend {
+		case "disk":
+			sourceStore, err = cache.NewDiskCacheRepositoryWithOptions(cache.DiskOptions{
+				Name:         "sources",
+				Path:         cfg.Cache.Source.Path,
+				MaxSizeBytes: capacity,
+				TTL:          cfg.Cache.Source.TTL,
+				Eviction:     "lru",
+			}, log)
+			if err != nil {
+				log.Error("failed to create source cache", slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+		default:
+			sourceStore = cache.NewMemoryCacheRepositoryWithCapacity(capacity, cfg.Cache.Source.TTL, log)
+		}
+
+		for name, origin := range origins {
+			origins[name] = storage.NewCachedOriginRepository(name, origin, sourceStore, cfg, log)
+		}
+		log.Info("source cache enabled", slog.String("backend", cfg.Cache.Source.Backend), slog.Int("capacity_mb", cfg.Cache.Source.CapacityMB))
 	}
-	log.Info("redis cache repository initialized")
 
-	transformationService := transformation.NewService(log, s3OriginRepo, cacheRepo, httpOriginRepo)
+	var (
+		redisCacheRepo *cache.RedisCacheRepository
+		cacheRepo      ports.CacheRepository
+	)
 
-	apiHandler := api.NewHandler(transformationService, log, cfg)
+	switch cfg.Cache.Backend {
+	case "disk":
+		diskCacheRepo, err := cache.NewDiskCacheRepository(cfg, log)
+		if err != nil {
+			log.Error("failed to create disk cache repository", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		cacheRepo = diskCacheRepo
+		log.Info("disk cache repository initialized", slog.String("path", cfg.Cache.Disk.Path))
+	default:
+		redisCacheRepo, err = cache.NewRedisCacheRepository(context.Background(), cfg, log)
+		if err != nil {
+			log.Error("failed to create redis cache repository", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		cacheRepo = redisCacheRepo
+		checks = append(checks, health.Check{Name: "redis", Optional: true, Run: redisCacheRepo.Ping})
+		log.Info("redis cache repository initialized")
+	}
+
+	tiers := []cache.Tier{{Name: cfg.Cache.Backend, Repo: cacheRepo}}
+
+	if cfg.Cache.Memory.Enabled {
+		memoryCacheRepo := cache.NewMemoryCacheRepository(cfg, log)
+		tiers = append([]cache.Tier{{Name: "memory", Repo: memoryCacheRepo}}, tiers...)
+		log.Info("in-memory cache tier enabled", slog.Int("capacity_mb", cfg.Cache.Memory.CapacityMB))
+	}
+
+	if cfg.Cache.S3.Enabled {
+		s3CacheRepo, err := cache.NewS3CacheRepository(context.Background(), cfg, log)
+		if err != nil {
+			log.Error("failed to create s3 cache repository", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		tiers = append(tiers, cache.Tier{Name: "s3", Repo: s3CacheRepo})
+		checks = append(checks, health.Check{Name: "s3_cache", Optional: true, Run: s3CacheRepo.Ping})
+		log.Info("s3 cache tier enabled", slog.String("bucket", cfg.Cache.S3.Bucket))
+	}
+
+	if len(tiers) > 1 {
+		cacheRepo = cache.NewTieredCacheRepository(log, tiers...)
+	}
+
+	var locker ports.Locker
+	if cfg.Coalescing.DistributedLock.Enabled {
+		locker = cache.NewRedisLocker(redisCacheRepo.Client(), log)
+		log.Info("distributed render lock enabled")
+	}
+
+	var variantIndex ports.VariantIndex
+	if redisCacheRepo != nil {
+		variantIndex = cache.NewRedisVariantIndex(redisCacheRepo.Client(), log)
+	} else {
+		variantIndex = cache.NewMemoryVariantIndex()
+	}
+
+	transformationService := transformation.NewService(log, cfg, origins, cacheRepo, locker, variantIndex)
+
+	var breakers []*circuitbreaker.Breaker
+	if redisCacheRepo != nil {
+		breakers = append(breakers, redisCacheRepo.Breaker())
+	}
+
+	checker := health.NewChecker(cfg.Health.Timeout, cfg.Health.CacheTTL, checks...)
+
+	apiHandler := api.NewHandler(transformationService, log, cfg, checker, breakers...)
 
 	mux := http.NewServeMux()
 
@@ -108,5 +209,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := transformationService.Close(ctx); err != nil {
+		log.Error("failed to drain cache writes", slog.String("error", err.Error()))
+	}
+
 	log.Info("server shutdown gracefully")
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+)
+
+// runPurge implements `chimera purge`, which asks a running instance to drop
+// every variant of a source path, prefix or tag.
+func runPurge(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	addr := fs.String("addr", fmt.Sprintf("http://localhost:%d", cfg.HttpSever.Port), "base url of a running chimera instance")
+	token := fs.String("token", cfg.Security.PurgeToken, "purge token, defaults to security.purge_token")
+	path := fs.String("path", "", "purge every variant of this source path")
+	prefix := fs.String("prefix", "", "purge every variant of the source paths starting with this prefix")
+	tag := fs.String("tag", "", "purge every variant carrying this tag")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	body, err := json.Marshal(domain.PurgeSelector{Path: *path, Prefix: *prefix, Tag: *tag})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(*addr, "/")+"/purge", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+*token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "purge request failed:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		fmt.Fprintf(os.Stderr, "purge rejected with status %d: %s", resp.StatusCode, msg)
+		return 1
+	}
+
+	var result struct {
+		Removed int `json:"removed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid purge response:", err)
+		return 1
+	}
+
+	fmt.Printf("removed %d variants\n", result.Removed)
+	return 0
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elect0/chimera/internal/domain"
+)
+
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrOverloaded):
+		retryAfter := int(h.cfg.Processing.RetryAfter.Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+		http.Error(w, "server is busy, try again later", http.StatusServiceUnavailable)
+	case errors.Is(err, domain.ErrSourceUnavailable):
+		retryAfter := int(h.cfg.Security.RemoteFetch.CircuitBreaker.OpenTimeout.Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, domain.ErrUnknownOrigin):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrSourceNotFound):
+		http.Error(w, "source image not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrSourceRejected):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrSourceUnsupported):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, domain.ErrSourceTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, domain.ErrSourceTooManyPixels), errors.Is(err, domain.ErrSourceTooManyFrames):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, "failed to process image", http.StatusInternalServerError)
+	}
+}
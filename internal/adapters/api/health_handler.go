@@ -1,11 +1,49 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/elect0/chimera/internal/circuitbreaker"
+	"github.com/elect0/chimera/internal/health"
 )
 
+// handleHealthCheck always answers 200 since chimera keeps serving from the
+// origin while a dependency is down, but reports the degraded state in the
+// body.
 func (h *Handler) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	var unavailable []string
+	for _, breaker := range h.breakers {
+		if breaker.State() != circuitbreaker.Closed {
+			unavailable = append(unavailable, breaker.Name())
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
+	if len(unavailable) > 0 {
+		fmt.Fprintln(w, "DEGRADED", unavailable)
+		return
+	}
 	fmt.Fprintln(w, "OK")
 }
+
+// handleLivez only reports that the process is up and serving http, restarting
+// chimera doesn't fix an unreachable dependency.
+func (h *Handler) handleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": health.StatusOK})
+}
+
+// handleReadyz answers 503 while a required dependency is down, together with
+// the result of every dependency check.
+func (h *Handler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.health.Report(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !report.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -40,11 +40,24 @@ func (h *Handler) handleImageTransformation(w http.ResponseWriter, r *http.Reque
 	wmOpacity, _ := strconv.ParseFloat(query.Get("wm_opacity"), 32)
 	wmPosStr := query.Get("wm_pos")
 
+	var tags []string
+	for _, tag := range strings.Split(query.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
 	if width <= 0 && height <= 0 {
 		http.Error(w, "at least one of 'width' or 'height' parameters is invalid", http.StatusBadRequest)
 		return
 	}
 
+	width, height, err := h.normalizeSize(width, height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	opts := domain.TransformationOptions{
 		Width:      width,
 		Height:     height,
@@ -56,16 +69,27 @@ func (h *Handler) handleImageTransformation(w http.ResponseWriter, r *http.Reque
 			Opacity:  float32(wmOpacity),
 			Position: mapGravity(wmPosStr),
 		},
+		Origin: query.Get("origin"),
+		Tags:   tags,
 	}
 
-	processedImage, err := h.service.Process(r.Context(), opts, imagePath)
+	variant, err := h.service.Process(r.Context(), opts, imagePath)
 	if err != nil {
-		http.Error(w, "failed to process image", http.StatusInternalServerError)
+		h.writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/"+bimg.ImageTypeName(targetType))
-	w.Write(processedImage)
+	w.Header().Set("ETag", variant.ETag())
+	w.Header().Set("Vary", "Accept")
+
+	if etagMatches(r.Header.Get("If-None-Match"), variant.ETag()) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", variant.ContentType())
+	w.Header().Set("Last-Modified", variant.CreatedAt.Format(http.TimeFormat))
+	w.Write(variant.Data)
 
 	h.log.Info("request processed successfully", slog.Duration("duration", time.Since(start)), slog.Int("status", http.StatusOK), slog.String("path", r.URL.Path))
 }
@@ -87,6 +111,16 @@ func (h *Handler) negotiateFormat(r *http.Request) bimg.ImageType {
 	return bimg.JPEG
 }
 
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 func mapGravity(pos string) bimg.Gravity {
 	switch pos {
 	case "north":
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/elect0/chimera/internal/domain"
+)
+
+type purgeResponse struct {
+	Removed int `json:"removed"`
+}
+
+func (h *Handler) handlePurge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var selector domain.PurgeSelector
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&selector); err != nil {
+		http.Error(w, "invalid purge request body", http.StatusBadRequest)
+		return
+	}
+
+	set := 0
+	for _, field := range []string{selector.Path, selector.Prefix, selector.Tag} {
+		if field != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		http.Error(w, "exactly one of 'path', 'prefix' or 'tag' is required", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := h.service.Purge(r.Context(), selector)
+	if err != nil {
+		h.log.Error("purge failed", slog.String("error", err.Error()))
+		http.Error(w, "failed to purge variants", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(purgeResponse{Removed: removed})
+}
+
+func (h *Handler) PurgeAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.Security.PurgeToken)) != 1 {
+			h.log.Warn("purge request rejected: invalid token")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -6,22 +6,30 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/elect0/chimera/internal/circuitbreaker"
 	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/health"
 	"github.com/elect0/chimera/internal/metrics"
 	"github.com/elect0/chimera/internal/ports"
 )
 
 type Handler struct {
-	service ports.TransformationService
-	log     *slog.Logger
-	cfg     *config.Config
+	service  ports.TransformationService
+	log      *slog.Logger
+	cfg      *config.Config
+	health   *health.Checker
+	breakers []*circuitbreaker.Breaker
 }
 
-func NewHandler(service ports.TransformationService, log *slog.Logger, cfg *config.Config) *Handler {
+// NewHandler builds the http handler. The breakers of optional dependencies
+// are reported by the health check.
+func NewHandler(service ports.TransformationService, log *slog.Logger, cfg *config.Config, checker *health.Checker, breakers ...*circuitbreaker.Breaker) *Handler {
 	return &Handler{
-		service: service,
-		log:     log,
-		cfg:     cfg,
+		service:  service,
+		log:      log,
+		cfg:      cfg,
+		health:   checker,
+		breakers: breakers,
 	}
 }
 
@@ -66,6 +74,8 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	transformHandler := http.HandlerFunc(h.handleImageTransformation)
 
 	mux.Handle("/health", h.MetricsMiddleware(healthHandler))
+	mux.Handle("/livez", h.MetricsMiddleware(http.HandlerFunc(h.handleLivez)))
+	mux.Handle("/readyz", h.MetricsMiddleware(http.HandlerFunc(h.handleReadyz)))
 
 	if h.cfg.Security.HMACEnabled {
 		h.log.Info("HMAC Signature validation is enabled for /transform")
@@ -75,4 +85,11 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 		mux.Handle("/transform", h.MetricsMiddleware(transformHandler))
 	}
 
+	if h.cfg.Security.PurgeToken != "" {
+		purgeHandler := http.HandlerFunc(h.handlePurge)
+		mux.Handle("/purge", h.MetricsMiddleware(h.PurgeAuthMiddleware(purgeHandler)))
+	} else {
+		h.log.Info("purge endpoint is disabled, set security.purge_token to enable it")
+	}
+
 }
@@ -0,0 +1,56 @@
+package api
+
+import "fmt"
+
+// normalizeSize maps a requested width/height onto the configured breakpoints
+// so that equivalent requests share a single cached variant.
+func (h *Handler) normalizeSize(width, height int) (int, int, error) {
+	sizes := h.cfg.Transform.Sizes
+
+	normalizedWidth, ok := normalizeDimension(width, sizes.Widths, sizes.Step, sizes.Mode)
+	if !ok {
+		return 0, 0, fmt.Errorf("width %d is not an allowed size", width)
+	}
+
+	normalizedHeight, ok := normalizeDimension(height, sizes.Heights, sizes.Step, sizes.Mode)
+	if !ok {
+		return 0, 0, fmt.Errorf("height %d is not an allowed size", height)
+	}
+
+	return normalizedWidth, normalizedHeight, nil
+}
+
+func normalizeDimension(value int, allowed []int, step int, mode string) (int, bool) {
+	if value <= 0 {
+		return value, true
+	}
+
+	if len(allowed) > 0 {
+		for _, breakpoint := range allowed {
+			if breakpoint == value {
+				return value, true
+			}
+			if breakpoint > value {
+				if mode == "reject" {
+					return 0, false
+				}
+				return breakpoint, true
+			}
+		}
+
+		if mode == "reject" {
+			return 0, false
+		}
+		// larger than every breakpoint, clamp to the biggest one
+		return allowed[len(allowed)-1], true
+	}
+
+	if step > 0 && value%step != 0 {
+		if mode == "reject" {
+			return 0, false
+		}
+		return (value/step + 1) * step, true
+	}
+
+	return value, true
+}
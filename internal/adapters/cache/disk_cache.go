@@ -0,0 +1,343 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/metrics"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+const tempFilePrefix = ".tmp-"
+
+type diskEntry struct {
+	size       int64
+	lastAccess time.Time
+	hits       int64
+}
+
+// DiskCacheRepository stores each variant in its own file under a two level
+// sharded directory tree. Writes go to a temporary file that is renamed into
+// place, so readers never observe a partially written variant.
+type DiskCacheRepository struct {
+	name     string
+	root     string
+	maxSize  int64
+	ttl      time.Duration
+	eviction string
+	log      *slog.Logger
+
+	mu      sync.Mutex
+	index   map[string]*diskEntry
+	size    int64
+	evictCh chan struct{}
+}
+
+type DiskOptions struct {
+	// Name labels the metrics of this cache.
+	Name         string
+	Path         string
+	MaxSizeBytes int64
+	TTL          time.Duration
+	Eviction     string
+}
+
+func NewDiskCacheRepository(cfg *config.Config, log *slog.Logger) (*DiskCacheRepository, error) {
+	return NewDiskCacheRepositoryWithOptions(DiskOptions{
+		Name:         "variants",
+		Path:         cfg.Cache.Disk.Path,
+		MaxSizeBytes: int64(cfg.Cache.Disk.MaxSizeMB) * 1024 * 1024,
+		TTL:          cfg.Cache.Disk.TTL,
+		Eviction:     cfg.Cache.Disk.Eviction,
+	}, log)
+}
+
+func NewDiskCacheRepositoryWithOptions(opts DiskOptions, log *slog.Logger) (*DiskCacheRepository, error) {
+	root := opts.Path
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	r := &DiskCacheRepository{
+		name:     opts.Name,
+		root:     root,
+		maxSize:  opts.MaxSizeBytes,
+		ttl:      opts.TTL,
+		eviction: opts.Eviction,
+		log:      log.With(slog.String("cache", "disk"), slog.String("name", opts.Name), slog.String("root", root)),
+		index:    make(map[string]*diskEntry),
+		evictCh:  make(chan struct{}, 1),
+	}
+
+	// temp files of writes made by this process are at least this recent, with
+	// some slack for file systems keeping modification times in whole seconds
+	startedAt := time.Now().Add(-2 * time.Second)
+
+	go r.evictLoop()
+	go r.rebuildIndex(startedAt)
+
+	return r, nil
+}
+
+func (r *DiskCacheRepository) Get(ctx context.Context, key string) (*domain.Variant, error) {
+	name := hashKey(key)
+	path := r.path(name)
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.ttl > 0 && time.Since(info.ModTime()) > r.ttl {
+		r.remove(name)
+		return nil, domain.ErrCacheMiss
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	variant, err := decodeStored(r.log, key, raw)
+	if err != nil {
+		r.remove(name)
+		return nil, err
+	}
+
+	r.mu.Lock()
+	entry, ok := r.index[name]
+	if !ok {
+		entry = &diskEntry{size: int64(len(raw))}
+		r.index[name] = entry
+		r.size += entry.size
+	}
+	entry.lastAccess = time.Now()
+	entry.hits++
+	r.mu.Unlock()
+
+	return variant, nil
+}
+
+func (r *DiskCacheRepository) Set(ctx context.Context, key string, variant *domain.Variant) error {
+	data, err := encodeEnvelope(variant)
+	if err != nil {
+		return err
+	}
+
+	name := hashKey(key)
+	path := r.path(name)
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	r.mu.Lock()
+	if entry, ok := r.index[name]; ok {
+		r.size -= entry.size
+	}
+	r.index[name] = &diskEntry{size: int64(len(data)), lastAccess: time.Now(), hits: 1}
+	r.size += int64(len(data))
+	overBudget := r.size > r.maxSize
+	metrics.DiskCacheSizeBytes.WithLabelValues(r.name).Set(float64(r.size))
+	r.mu.Unlock()
+
+	if overBudget {
+		r.triggerEviction()
+	}
+
+	return nil
+}
+
+func (r *DiskCacheRepository) Delete(ctx context.Context, keys ...string) (int, error) {
+	deleted := 0
+	for _, key := range keys {
+		if r.remove(hashKey(key)) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *DiskCacheRepository) path(name string) string {
+	return filepath.Join(r.root, name[0:2], name[2:4], name)
+}
+
+// remove deletes the file of an entry and reports whether it existed.
+func (r *DiskCacheRepository) remove(name string) bool {
+	err := os.Remove(r.path(name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		r.log.Error("failed to remove cache file", slog.String("file", name), slog.String("error", err.Error()))
+	}
+
+	r.mu.Lock()
+	if entry, ok := r.index[name]; ok {
+		r.size -= entry.size
+		delete(r.index, name)
+	}
+	metrics.DiskCacheSizeBytes.WithLabelValues(r.name).Set(float64(r.size))
+	r.mu.Unlock()
+
+	return err == nil
+}
+
+func (r *DiskCacheRepository) triggerEviction() {
+	select {
+	case r.evictCh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *DiskCacheRepository) evictLoop() {
+	for range r.evictCh {
+		r.evict()
+	}
+}
+
+// evict drops entries until the cache is back under 90% of its budget, so a
+// single write over the limit doesn't trigger an eviction pass on every Set.
+func (r *DiskCacheRepository) evict() {
+	target := r.maxSize / 10 * 9
+
+	r.mu.Lock()
+	if r.size <= r.maxSize {
+		r.mu.Unlock()
+		return
+	}
+
+	type candidate struct {
+		name  string
+		entry diskEntry
+	}
+
+	candidates := make([]candidate, 0, len(r.index))
+	for name, entry := range r.index {
+		candidates = append(candidates, candidate{name: name, entry: *entry})
+	}
+	size := r.size
+	r.mu.Unlock()
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if r.eviction == "lfu" && a.entry.hits != b.entry.hits {
+			if a.entry.hits < b.entry.hits {
+				return -1
+			}
+			return 1
+		}
+		return a.entry.lastAccess.Compare(b.entry.lastAccess)
+	})
+
+	evicted := 0
+	for _, c := range candidates {
+		if size <= target {
+			break
+		}
+		r.remove(c.name)
+		size -= c.entry.size
+		evicted++
+	}
+
+	metrics.DiskCacheEvictionsTotal.WithLabelValues(r.name).Add(float64(evicted))
+	r.log.Info("evicted entries from disk cache", slog.Int("evicted", evicted), slog.String("policy", r.eviction))
+}
+
+// rebuildIndex walks the cache directory so entries written by a previous
+// process count against the size budget. Files are served straight from disk
+// and written while the walk is still running, so only temp files older than
+// startedAt are leftovers.
+func (r *DiskCacheRepository) rebuildIndex(startedAt time.Time) {
+	start := time.Now()
+	files := 0
+
+	err := filepath.WalkDir(r.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		if strings.HasPrefix(d.Name(), tempFilePrefix) {
+			// leftovers from writes interrupted by a crash
+			if info.ModTime().Before(startedAt) {
+				os.Remove(path)
+			}
+			return nil
+		}
+
+		r.mu.Lock()
+		if _, ok := r.index[d.Name()]; !ok {
+			r.index[d.Name()] = &diskEntry{size: info.Size(), lastAccess: info.ModTime(), hits: 1}
+			r.size += info.Size()
+		}
+		r.mu.Unlock()
+		files++
+
+		return nil
+	})
+	if err != nil {
+		r.log.Error("failed to rebuild disk cache index", slog.String("error", err.Error()))
+	}
+
+	r.mu.Lock()
+	size := r.size
+	metrics.DiskCacheSizeBytes.WithLabelValues(r.name).Set(float64(size))
+	r.mu.Unlock()
+
+	r.log.Info("disk cache index rebuilt", slog.Int("files", files), slog.Int64("size_bytes", size), slog.Duration("duration", time.Since(start)))
+
+	if size > r.maxSize {
+		r.triggerEviction()
+	}
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+var _ ports.CacheRepository = (*DiskCacheRepository)(nil)
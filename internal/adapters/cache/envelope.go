@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/h2non/bimg"
+)
+
+// Variants are stored as an envelope:
+//
+//	magic (4 bytes) | version (1 byte) | header length (4 bytes, big endian) | JSON header | image data
+//
+// Bumping envelopeVersion makes every entry written in an older format read as
+// a miss, so a format change only costs a re-render instead of a migration.
+const envelopeVersion = 1
+
+var envelopeMagic = []byte("CHMR")
+
+var errUnknownEnvelope = errors.New("unknown cache envelope format")
+
+type envelopeHeader struct {
+	Type          string    `json:"type"`
+	Width         int       `json:"width"`
+	Height        int       `json:"height"`
+	SourceVersion string    `json:"source_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at,omitzero"`
+	Checksum      string    `json:"checksum"`
+}
+
+func encodeEnvelope(v *domain.Variant) ([]byte, error) {
+	header, err := json.Marshal(envelopeHeader{
+		Type:          bimg.ImageTypeName(v.ImageType),
+		Width:         v.Width,
+		Height:        v.Height,
+		SourceVersion: v.SourceVersion,
+		CreatedAt:     v.CreatedAt,
+		ExpiresAt:     v.ExpiresAt,
+		Checksum:      v.Checksum,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(envelopeMagic)+5+len(header)+len(v.Data)))
+	buf.Write(envelopeMagic)
+	buf.WriteByte(envelopeVersion)
+	binary.Write(buf, binary.BigEndian, uint32(len(header)))
+	buf.Write(header)
+	buf.Write(v.Data)
+
+	return buf.Bytes(), nil
+}
+
+func decodeEnvelope(raw []byte) (*domain.Variant, error) {
+	prefixLen := len(envelopeMagic) + 5
+	if len(raw) < prefixLen || !bytes.Equal(raw[:len(envelopeMagic)], envelopeMagic) || raw[len(envelopeMagic)] != envelopeVersion {
+		return nil, errUnknownEnvelope
+	}
+
+	headerLen := int(binary.BigEndian.Uint32(raw[len(envelopeMagic)+1 : prefixLen]))
+	if len(raw) < prefixLen+headerLen {
+		return nil, fmt.Errorf("truncated cache envelope header")
+	}
+
+	var header envelopeHeader
+	if err := json.Unmarshal(raw[prefixLen:prefixLen+headerLen], &header); err != nil {
+		return nil, fmt.Errorf("invalid cache envelope header: %w", err)
+	}
+
+	data := raw[prefixLen+headerLen:]
+	if domain.Checksum(data) != header.Checksum {
+		return nil, fmt.Errorf("cache envelope checksum mismatch")
+	}
+
+	return &domain.Variant{
+		Data:          data,
+		ImageType:     imageTypeFromName(header.Type),
+		Width:         header.Width,
+		Height:        header.Height,
+		SourceVersion: header.SourceVersion,
+		CreatedAt:     header.CreatedAt,
+		ExpiresAt:     header.ExpiresAt,
+		Checksum:      header.Checksum,
+	}, nil
+}
+
+// decodeStored turns unreadable entries into misses, so they are re-rendered
+// and overwritten instead of failing the request.
+func decodeStored(log *slog.Logger, key string, raw []byte) (*domain.Variant, error) {
+	variant, err := decodeEnvelope(raw)
+	if err != nil {
+		log.Warn("discarding unreadable cache entry", slog.String("key", key), slog.String("error", err.Error()))
+		return nil, domain.ErrCacheMiss
+	}
+	if variant.Expired() {
+		return nil, domain.ErrCacheMiss
+	}
+	return variant, nil
+}
+
+func imageTypeFromName(name string) bimg.ImageType {
+	for imageType, typeName := range bimg.ImageTypes {
+		if typeName == name {
+			return imageType
+		}
+	}
+	return bimg.UNKNOWN
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/lru"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+// MemoryCacheRepository keeps variants in process memory. Stored variants are
+// handed out as-is, callers must treat them as read-only.
+type MemoryCacheRepository struct {
+	items *lru.Cache[*domain.Variant]
+	log   *slog.Logger
+	ttl   time.Duration
+}
+
+func NewMemoryCacheRepository(cfg *config.Config, log *slog.Logger) *MemoryCacheRepository {
+	return NewMemoryCacheRepositoryWithCapacity(int64(cfg.Cache.Memory.CapacityMB)*1024*1024, cfg.Cache.Memory.TTL, log)
+}
+
+func NewMemoryCacheRepositoryWithCapacity(capacityBytes int64, ttl time.Duration, log *slog.Logger) *MemoryCacheRepository {
+	return &MemoryCacheRepository{
+		items: lru.New[*domain.Variant](capacityBytes),
+		log:   log,
+		ttl:   ttl,
+	}
+}
+
+func (r *MemoryCacheRepository) Get(ctx context.Context, key string) (*domain.Variant, error) {
+	variant, ok := r.items.Get(key)
+	if !ok || variant.Expired() {
+		return nil, domain.ErrCacheMiss
+	}
+	return variant, nil
+}
+
+func (r *MemoryCacheRepository) Set(ctx context.Context, key string, variant *domain.Variant) error {
+	if !r.items.Set(key, variant, int64(len(variant.Data)), r.ttl) {
+		r.log.Debug("item too large for memory cache", slog.String("key", key), slog.Int("size_bytes", len(variant.Data)))
+	}
+	return nil
+}
+
+func (r *MemoryCacheRepository) Delete(ctx context.Context, keys ...string) (int, error) {
+	deleted := 0
+	for _, key := range keys {
+		if r.items.Delete(key) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+var _ ports.CacheRepository = (*MemoryCacheRepository)(nil)
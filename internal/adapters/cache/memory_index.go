@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+type indexSet struct {
+	keys      map[string]struct{}
+	expiresAt time.Time
+}
+
+// MemoryVariantIndex is the process local index used when there is no redis
+// to share it through, e.g. with the disk backend. It starts out empty, so
+// variants written before a restart can't be purged by path or tag.
+type MemoryVariantIndex struct {
+	mu    sync.Mutex
+	paths map[string]*indexSet
+	tags  map[string]*indexSet
+	adds  int
+}
+
+func NewMemoryVariantIndex() *MemoryVariantIndex {
+	return &MemoryVariantIndex{
+		paths: make(map[string]*indexSet),
+		tags:  make(map[string]*indexSet),
+	}
+}
+
+func (i *MemoryVariantIndex) Add(ctx context.Context, key, sourcePath string, tags []string, ttl time.Duration) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	addToSet(i.paths, sourcePath, key, expiresAt)
+	for _, tag := range tags {
+		addToSet(i.tags, tag, key, expiresAt)
+	}
+
+	i.adds++
+	if i.adds%10000 == 0 {
+		pruneSets(i.paths)
+		pruneSets(i.tags)
+	}
+
+	return nil
+}
+
+func (i *MemoryVariantIndex) Lookup(ctx context.Context, selector domain.PurgeSelector) ([]string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	sets, err := i.match(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, set := range sets {
+		for key := range set.keys {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (i *MemoryVariantIndex) Remove(ctx context.Context, selector domain.PurgeSelector) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	switch {
+	case selector.Path != "":
+		delete(i.paths, selector.Path)
+	case selector.Tag != "":
+		delete(i.tags, selector.Tag)
+	case selector.Prefix != "":
+		for path := range i.paths {
+			if strings.HasPrefix(path, selector.Prefix) {
+				delete(i.paths, path)
+			}
+		}
+	default:
+		return errors.New("purge selector is empty")
+	}
+	return nil
+}
+
+func (i *MemoryVariantIndex) match(selector domain.PurgeSelector) ([]*indexSet, error) {
+	switch {
+	case selector.Path != "":
+		if set, ok := i.paths[selector.Path]; ok {
+			return []*indexSet{set}, nil
+		}
+		return nil, nil
+	case selector.Tag != "":
+		if set, ok := i.tags[selector.Tag]; ok {
+			return []*indexSet{set}, nil
+		}
+		return nil, nil
+	case selector.Prefix != "":
+		var sets []*indexSet
+		for path, set := range i.paths {
+			if strings.HasPrefix(path, selector.Prefix) {
+				sets = append(sets, set)
+			}
+		}
+		return sets, nil
+	default:
+		return nil, errors.New("purge selector is empty")
+	}
+}
+
+func addToSet(sets map[string]*indexSet, name, key string, expiresAt time.Time) {
+	set, ok := sets[name]
+	if !ok {
+		set = &indexSet{keys: make(map[string]struct{})}
+		sets[name] = set
+	}
+	set.keys[key] = struct{}{}
+	if expiresAt.After(set.expiresAt) {
+		set.expiresAt = expiresAt
+	}
+}
+
+func pruneSets(sets map[string]*indexSet) {
+	now := time.Now()
+	for name, set := range sets {
+		if now.After(set.expiresAt) {
+			delete(sets, name)
+		}
+	}
+}
+
+var _ ports.VariantIndex = (*MemoryVariantIndex)(nil)
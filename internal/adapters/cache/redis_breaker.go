@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/elect0/chimera/internal/circuitbreaker"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// breakerHook guards every command sent through a redis client, so the cache,
+// the render lock and the variant index all stop waiting on a redis that is
+// down and fail right away with domain.ErrCacheUnavailable instead.
+type breakerHook struct {
+	breaker *circuitbreaker.Breaker
+}
+
+func (h breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !h.breaker.Allow() {
+			err := unavailable()
+			cmd.SetErr(err)
+			return err
+		}
+
+		err := next(ctx, cmd)
+		h.record(ctx, err)
+		return err
+	}
+}
+
+func (h breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !h.breaker.Allow() {
+			err := unavailable()
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+
+		err := next(ctx, cmds)
+		h.record(ctx, err)
+		return err
+	}
+}
+
+// record counts only errors that say something about redis itself. Misses and
+// error replies come from a healthy server, and a caller giving up early is
+// neither a success nor a failure.
+func (h breakerHook) record(ctx context.Context, err error) {
+	var replyErr redis.Error
+	switch {
+	case err == nil, errors.Is(err, redis.Nil):
+		h.breaker.Success()
+	case errors.Is(ctx.Err(), context.Canceled):
+	case errors.As(err, &replyErr):
+		h.breaker.Success()
+	default:
+		h.breaker.Failure()
+	}
+}
+
+func unavailable() error {
+	return fmt.Errorf("%w: %w", domain.ErrCacheUnavailable, circuitbreaker.ErrOpen)
+}
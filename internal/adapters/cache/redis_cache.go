@@ -2,47 +2,96 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"time"
 
+	"github.com/elect0/chimera/internal/circuitbreaker"
 	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
 	"github.com/elect0/chimera/internal/ports"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisCacheRepository struct {
-	client *redis.Client
-	log    *slog.Logger
-	ttl    time.Duration
+	client  redis.UniversalClient
+	breaker *circuitbreaker.Breaker
+	log     *slog.Logger
+	ttl     time.Duration
 }
 
 func NewRedisCacheRepository(ctx context.Context, cfg *config.Config, log *slog.Logger) (*RedisCacheRepository, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Address,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	breaker := circuitbreaker.New("redis", circuitbreaker.Options{
+		FailureThreshold: cfg.Redis.CircuitBreaker.FailureThreshold,
+		OpenTimeout:      cfg.Redis.CircuitBreaker.OpenTimeout,
 	})
+	client.AddHook(breakerHook{breaker: breaker})
 
+	// redis being down at startup only means starting in origin-only mode, the
+	// breaker probes it until it comes back
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, err
+		log.Warn("redis is unreachable, serving from the origin until it recovers", slog.String("error", err.Error()))
+		breaker.Trip()
 	}
 
-	ttl := 1 * time.Hour
-
 	return &RedisCacheRepository{
-		client: client,
-		log:    log,
-		ttl:    ttl,
+		client:  client,
+		breaker: breaker,
+		log:     log,
+		ttl:     cfg.Cache.TTL,
 	}, nil
 }
 
-func (r *RedisCacheRepository) Get(ctx context.Context, key string) ([]byte, error) {
-	return r.client.Get(ctx, key).Bytes()
+func (r *RedisCacheRepository) Client() redis.UniversalClient {
+	return r.client
 }
 
-func (r *RedisCacheRepository) Set(ctx context.Context, key string, data []byte) error {
-	return r.client.Set(ctx, key, data, r.ttl).Err()
+func (r *RedisCacheRepository) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
 }
 
-var _ ports.CacheRepository = (*RedisCacheRepository)(nil)
+func (r *RedisCacheRepository) Breaker() *circuitbreaker.Breaker {
+	return r.breaker
+}
 
+func (r *RedisCacheRepository) Get(ctx context.Context, key string) (*domain.Variant, error) {
+	raw, err := r.client.Get(ctx, slotKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) || errors.Is(err, domain.ErrCacheUnavailable) {
+		return nil, domain.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeStored(r.log, key, raw)
+}
+
+func (r *RedisCacheRepository) Set(ctx context.Context, key string, variant *domain.Variant) error {
+	ttl := r.ttl
+	if !variant.ExpiresAt.IsZero() {
+		ttl = time.Until(variant.ExpiresAt)
+		if ttl <= 0 {
+			return nil
+		}
+	}
+
+	raw, err := encodeEnvelope(variant)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, slotKey(key), raw, ttl).Err()
+}
+
+func (r *RedisCacheRepository) Delete(ctx context.Context, keys ...string) (int, error) {
+	slotKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		slotKeys = append(slotKeys, slotKey(key))
+	}
+	return deleteKeys(ctx, r.client, slotKeys)
+}
+
+var _ ports.CacheRepository = (*RedisCacheRepository)(nil)
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisClient connects to a single node, a Sentinel managed master or a
+// cluster depending on redis.mode. All modes share auth, TLS and pool settings.
+func newRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	redisCfg := cfg.Redis
+
+	tlsConfig, err := redisTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := redisCfg.Addresses
+	if len(addresses) == 0 {
+		addresses = []string{redisCfg.Address}
+	}
+
+	switch redisCfg.Mode {
+	case "sentinel":
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       redisCfg.MasterName,
+			SentinelAddrs:    addresses,
+			SentinelUsername: redisCfg.SentinelUsername,
+			SentinelPassword: redisCfg.SentinelPassword,
+			Username:         redisCfg.Username,
+			Password:         redisCfg.Password,
+			DB:               redisCfg.DB,
+			TLSConfig:        tlsConfig,
+			PoolSize:         redisCfg.PoolSize,
+			MinIdleConns:     redisCfg.MinIdleConns,
+			MaxRetries:       redisCfg.MaxRetries,
+			DialTimeout:      redisCfg.DialTimeout,
+			ReadTimeout:      redisCfg.ReadTimeout,
+			WriteTimeout:     redisCfg.WriteTimeout,
+			PoolTimeout:      redisCfg.PoolTimeout,
+		}), nil
+	case "cluster":
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        addresses,
+			Username:     redisCfg.Username,
+			Password:     redisCfg.Password,
+			TLSConfig:    tlsConfig,
+			PoolSize:     redisCfg.PoolSize,
+			MinIdleConns: redisCfg.MinIdleConns,
+			MaxRetries:   redisCfg.MaxRetries,
+			DialTimeout:  redisCfg.DialTimeout,
+			ReadTimeout:  redisCfg.ReadTimeout,
+			WriteTimeout: redisCfg.WriteTimeout,
+			PoolTimeout:  redisCfg.PoolTimeout,
+		}), nil
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:         redisCfg.Address,
+			Username:     redisCfg.Username,
+			Password:     redisCfg.Password,
+			DB:           redisCfg.DB,
+			TLSConfig:    tlsConfig,
+			PoolSize:     redisCfg.PoolSize,
+			MinIdleConns: redisCfg.MinIdleConns,
+			MaxRetries:   redisCfg.MaxRetries,
+			DialTimeout:  redisCfg.DialTimeout,
+			ReadTimeout:  redisCfg.ReadTimeout,
+			WriteTimeout: redisCfg.WriteTimeout,
+			PoolTimeout:  redisCfg.PoolTimeout,
+		}), nil
+	}
+}
+
+func redisTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsCfg := cfg.Redis.TLS
+	if !tlsCfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         tlsCfg.ServerName,
+		InsecureSkipVerify: tlsCfg.InsecureSkipVerify,
+	}
+
+	if tlsCfg.CAFile != "" {
+		ca, err := os.ReadFile(tlsCfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis ca file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("redis ca file contains no certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if tlsCfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// slotKey keeps user supplied braces from acting as a cluster hash tag, which
+// would pile every variant sharing it onto one slot. A leading empty tag makes
+// redis hash the whole key instead.
+func slotKey(key string) string {
+	if strings.Contains(key, "{") {
+		return "{}" + key
+	}
+	return key
+}
+
+// deleteKeys removes keys one command at a time in a pipeline, since a
+// multi-key DEL fails in a cluster once the keys live in different slots.
+func deleteKeys(ctx context.Context, client redis.UniversalClient, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	pipe := client.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Del(ctx, key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, cmd := range cmds {
+		deleted += int(cmd.Val())
+	}
+	return deleted, nil
+}
+
+// scanKeys collects the keys matching pattern. A cluster client scans every
+// master, as SCAN only walks the keyspace of the node it is sent to.
+func scanKeys(ctx context.Context, client redis.UniversalClient, pattern string) ([]string, error) {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, client, pattern)
+	}
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		nodeKeys, err := scanNode(ctx, node, pattern)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, nodeKeys...)
+		mu.Unlock()
+		return nil
+	})
+	return keys, err
+}
+
+func scanNode(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/ports"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	pathIndexPrefix = "chimera:variants:path:"
+	tagIndexPrefix  = "chimera:variants:tag:"
+)
+
+// RedisVariantIndex keeps one set of variant keys per source path and per tag.
+// Every set expires together with the longest lived variant it references.
+type RedisVariantIndex struct {
+	client redis.UniversalClient
+	log    *slog.Logger
+}
+
+func NewRedisVariantIndex(client redis.UniversalClient, log *slog.Logger) *RedisVariantIndex {
+	return &RedisVariantIndex{
+		client: client,
+		log:    log,
+	}
+}
+
+func (i *RedisVariantIndex) Add(ctx context.Context, key, sourcePath string, tags []string, ttl time.Duration) error {
+	indexKeys := []string{slotKey(pathIndexPrefix + sourcePath)}
+	for _, tag := range tags {
+		indexKeys = append(indexKeys, slotKey(tagIndexPrefix+tag))
+	}
+
+	pipe := i.client.Pipeline()
+	for _, indexKey := range indexKeys {
+		pipe.SAdd(ctx, indexKey, key)
+		pipe.ExpireGT(ctx, indexKey, ttl)
+		// ExpireGT leaves keys without an expiry untouched, so set one on new sets
+		pipe.ExpireNX(ctx, indexKey, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (i *RedisVariantIndex) Lookup(ctx context.Context, selector domain.PurgeSelector) ([]string, error) {
+	indexKeys, err := i.indexKeys(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, indexKey := range indexKeys {
+		members, err := i.client.SMembers(ctx, indexKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, members...)
+	}
+
+	return keys, nil
+}
+
+func (i *RedisVariantIndex) Remove(ctx context.Context, selector domain.PurgeSelector) error {
+	indexKeys, err := i.indexKeys(ctx, selector)
+	if err != nil {
+		return err
+	}
+
+	_, err = deleteKeys(ctx, i.client, indexKeys)
+	return err
+}
+
+func (i *RedisVariantIndex) indexKeys(ctx context.Context, selector domain.PurgeSelector) ([]string, error) {
+	switch {
+	case selector.Path != "":
+		return []string{slotKey(pathIndexPrefix + selector.Path)}, nil
+	case selector.Tag != "":
+		return []string{slotKey(tagIndexPrefix + selector.Tag)}, nil
+	case selector.Prefix != "":
+		return i.scanPathKeys(ctx, selector.Prefix)
+	default:
+		return nil, errors.New("purge selector is empty")
+	}
+}
+
+// scanPathKeys finds the path sets under prefix. Paths with braces are stored
+// under a "{}" tag by slotKey, so they are scanned for separately.
+func (i *RedisVariantIndex) scanPathKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for _, keyPrefix := range []string{pathIndexPrefix, "{}" + pathIndexPrefix} {
+		found, err := scanKeys(ctx, i.client, escapeGlob(keyPrefix+prefix)+"*")
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, found...)
+	}
+	return keys, nil
+}
+
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+var _ ports.VariantIndex = (*RedisVariantIndex)(nil)
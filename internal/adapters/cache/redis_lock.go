@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/elect0/chimera/internal/ports"
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseScript only deletes the lock if it is still owned by the caller, so an
+// expired lock that was picked up by another replica is never released by us.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type RedisLocker struct {
+	client redis.UniversalClient
+	log    *slog.Logger
+}
+
+func NewRedisLocker(client redis.UniversalClient, log *slog.Logger) *RedisLocker {
+	return &RedisLocker{
+		client: client,
+		log:    log,
+	}
+}
+
+func (l *RedisLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	lockKey := slotKey("lock:" + key)
+
+	acquired, err := l.client.SetNX(ctx, lockKey, token, ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !acquired {
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		if err := releaseScript.Run(context.Background(), l.client, []string{lockKey}, token).Err(); err != nil {
+			l.log.Error("failed to release lock", slog.String("key", lockKey), slog.String("error", err.Error()))
+		}
+	}
+
+	return unlock, true, nil
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var _ ports.Locker = (*RedisLocker)(nil)
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/elect0/chimera/internal/adapters/s3client"
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/ports"
+	"github.com/h2non/bimg"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	metaCreatedAt     = "created-at"
+	metaExpiresAt     = "expires-at"
+	metaCacheKey      = "cache-key"
+	metaImageType     = "image-type"
+	metaWidth         = "width"
+	metaHeight        = "height"
+	metaSourceVersion = "source-version"
+	metaChecksum      = "checksum"
+	// objectTagging lets bucket lifecycle rules target derivatives by tag
+	// instead of relying on the prefix alone.
+	objectTagging = "chimera-tier=derivative"
+)
+
+// S3CacheRepository persists variants in a dedicated bucket so they survive
+// redis flushes and can warm up a cold cluster.
+type S3CacheRepository struct {
+	client    *s3.Client
+	bucket    string
+	prefix    string
+	keyLayout string
+	ttl       time.Duration
+	log       *slog.Logger
+}
+
+func NewS3CacheRepository(ctx context.Context, cfg *config.Config, log *slog.Logger) (*S3CacheRepository, error) {
+	client, err := s3client.New(ctx, cfg.Cache.S3.S3Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3CacheRepository{
+		client:    client,
+		bucket:    cfg.Cache.S3.Bucket,
+		prefix:    cfg.Cache.S3.Prefix,
+		keyLayout: cfg.Cache.S3.KeyLayout,
+		ttl:       cfg.Cache.S3.TTL,
+		log:       log.With(slog.String("cache", "s3"), slog.String("bucket", cfg.Cache.S3.Bucket)),
+	}, nil
+}
+
+// Ping checks that the bucket exists and is accessible with our credentials.
+func (r *S3CacheRepository) Ping(ctx context.Context) error {
+	_, err := r.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(r.bucket)})
+	return err
+}
+
+// Variants are stored as plain image objects with their envelope fields kept
+// in the object metadata, so the bucket can be browsed and served directly.
+func (r *S3CacheRepository) Get(ctx context.Context, key string) (*domain.Variant, error) {
+	result, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.objectKey(key)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, domain.ErrCacheMiss
+		}
+		return nil, err
+	}
+
+	defer result.Body.Close()
+
+	meta := result.Metadata
+
+	createdAt, err := time.Parse(time.RFC3339, meta[metaCreatedAt])
+	if err != nil {
+		r.log.Warn("discarding cache object without creation time", slog.String("key", key))
+		return nil, domain.ErrCacheMiss
+	}
+
+	if r.ttl > 0 && time.Since(createdAt) > r.ttl {
+		return nil, domain.ErrCacheMiss
+	}
+
+	var expiresAt time.Time
+	if raw := meta[metaExpiresAt]; raw != "" {
+		expiresAt, err = time.Parse(time.RFC3339, raw)
+		if err != nil || time.Now().After(expiresAt) {
+			return nil, domain.ErrCacheMiss
+		}
+	}
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := meta[metaChecksum]
+	if checksum != domain.Checksum(data) {
+		r.log.Warn("discarding cache object with checksum mismatch", slog.String("key", key))
+		return nil, domain.ErrCacheMiss
+	}
+
+	width, _ := strconv.Atoi(meta[metaWidth])
+	height, _ := strconv.Atoi(meta[metaHeight])
+
+	return &domain.Variant{
+		Data:          data,
+		ImageType:     imageTypeFromName(meta[metaImageType]),
+		Width:         width,
+		Height:        height,
+		SourceVersion: meta[metaSourceVersion],
+		CreatedAt:     createdAt,
+		ExpiresAt:     expiresAt,
+		Checksum:      checksum,
+	}, nil
+}
+
+func (r *S3CacheRepository) Set(ctx context.Context, key string, variant *domain.Variant) error {
+	metadata := map[string]string{
+		metaCreatedAt:     variant.CreatedAt.Format(time.RFC3339),
+		metaCacheKey:      url.PathEscape(key),
+		metaImageType:     bimg.ImageTypeName(variant.ImageType),
+		metaWidth:         strconv.Itoa(variant.Width),
+		metaHeight:        strconv.Itoa(variant.Height),
+		metaSourceVersion: variant.SourceVersion,
+		metaChecksum:      variant.Checksum,
+	}
+	if !variant.ExpiresAt.IsZero() {
+		metadata[metaExpiresAt] = variant.ExpiresAt.Format(time.RFC3339)
+	}
+
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(r.objectKey(key)),
+		Body:        bytes.NewReader(variant.Data),
+		ContentType: aws.String(variant.ContentType()),
+		Tagging:     aws.String(objectTagging),
+		Metadata:    metadata,
+	})
+	return err
+}
+
+// Delete only deletes the objects that exist, as S3 reports every key of a
+// DeleteObjects call as deleted whether it was stored or not.
+func (r *S3CacheRepository) Delete(ctx context.Context, keys ...string) (int, error) {
+	var (
+		mu       sync.Mutex
+		existing []string
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(16)
+	for _, key := range keys {
+		g.Go(func() error {
+			_, err := r.client.HeadObject(gctx, &s3.HeadObjectInput{
+				Bucket: aws.String(r.bucket),
+				Key:    aws.String(r.objectKey(key)),
+			})
+			if err != nil {
+				var notFound *types.NotFound
+				if errors.As(err, &notFound) {
+					return nil
+				}
+				return err
+			}
+			mu.Lock()
+			existing = append(existing, key)
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+
+	// DeleteObjects accepts at most 1000 keys per call
+	deleted := 0
+	for batch := range slices.Chunk(existing, 1000) {
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(r.objectKey(key))})
+		}
+
+		_, err := r.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(r.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += len(batch)
+	}
+	return deleted, nil
+}
+
+// objectKey maps a cache key onto the bucket. The "source" layout keeps the
+// variants of one source image next to each other under the prefix, while
+// "hashed" spreads them evenly and keeps object keys short.
+func (r *S3CacheRepository) objectKey(key string) string {
+	if r.keyLayout == "hashed" {
+		name := hashKey(key)
+		key = name[0:2] + "/" + name
+	}
+
+	if r.prefix == "" {
+		return key
+	}
+	return strings.TrimSuffix(r.prefix, "/") + "/" + key
+}
+
+var _ ports.CacheRepository = (*S3CacheRepository)(nil)
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/metrics"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+type Tier struct {
+	Name string
+	Repo ports.CacheRepository
+}
+
+// TieredCacheRepository looks tiers up in order, fastest first, and copies
+// entries found in a slower tier into every tier in front of it.
+type TieredCacheRepository struct {
+	tiers []Tier
+	log   *slog.Logger
+}
+
+func NewTieredCacheRepository(log *slog.Logger, tiers ...Tier) *TieredCacheRepository {
+	return &TieredCacheRepository{
+		tiers: tiers,
+		log:   log,
+	}
+}
+
+func (r *TieredCacheRepository) Get(ctx context.Context, key string) (*domain.Variant, error) {
+	for i, tier := range r.tiers {
+		variant, err := tier.Repo.Get(ctx, key)
+		if err != nil {
+			metrics.CacheTierMissesTotal.WithLabelValues(tier.Name).Inc()
+			if !errors.Is(err, domain.ErrCacheMiss) {
+				r.log.Error("error getting from cache tier", slog.String("tier", tier.Name), slog.String("error", err.Error()))
+			}
+			continue
+		}
+
+		metrics.CacheTierHitsTotal.WithLabelValues(tier.Name).Inc()
+		r.promote(ctx, key, variant, r.tiers[:i])
+		return variant, nil
+	}
+
+	return nil, domain.ErrCacheMiss
+}
+
+// Set writes to every tier. When only some of them fail, the error is a
+// PartialWriteError, as the variant can still be served from the others.
+func (r *TieredCacheRepository) Set(ctx context.Context, key string, variant *domain.Variant) error {
+	var errs []error
+	for _, tier := range r.tiers {
+		if err := tier.Repo.Set(ctx, key, variant); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 && len(errs) < len(r.tiers) {
+		return &domain.PartialWriteError{Err: errors.Join(errs...)}
+	}
+	return errors.Join(errs...)
+}
+
+// Delete reports the most entries removed from a single tier, as a variant
+// usually lives in several tiers at once and counting every copy would
+// overstate how many variants were removed.
+func (r *TieredCacheRepository) Delete(ctx context.Context, keys ...string) (int, error) {
+	var (
+		deleted int
+		errs    []error
+	)
+	for _, tier := range r.tiers {
+		n, err := tier.Repo.Delete(ctx, keys...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		deleted = max(deleted, n)
+	}
+	return deleted, errors.Join(errs...)
+}
+
+func (r *TieredCacheRepository) promote(ctx context.Context, key string, variant *domain.Variant, tiers []Tier) {
+	for _, tier := range tiers {
+		if err := tier.Repo.Set(ctx, key, variant); err != nil && !errors.Is(err, domain.ErrCacheUnavailable) {
+			r.log.Error("failed to promote item to cache tier", slog.String("tier", tier.Name), slog.String("error", err.Error()))
+		}
+	}
+}
+
+var _ ports.CacheRepository = (*TieredCacheRepository)(nil)
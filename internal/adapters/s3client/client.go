@@ -0,0 +1,47 @@
+// Package s3client builds S3 clients shared by the origin and cache adapters.
+package s3client
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/elect0/chimera/internal/config"
+)
+
+// New builds a client for AWS or any S3-compatible store such as MinIO, Ceph
+// or R2. Static credentials win over a named profile, which wins over the
+// default credential chain.
+func New(ctx context.Context, c config.S3Client) (*s3.Client, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(c.Region)}
+
+	switch {
+	case c.AccessKeyID != "":
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey, c.SessionToken),
+		))
+	case c.Profile != "":
+		opts = append(opts, awsconfig.WithSharedConfigProfile(c.Profile))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if c.Endpoint != "" {
+			o.BaseEndpoint = aws.String(c.Endpoint)
+		}
+		o.UsePathStyle = c.UsePathStyle
+
+		// many S3-compatible stores reject or don't return the checksums that
+		// AWS computes by default since early 2025
+		if c.ChecksumValidation == "when_required" {
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+	}), nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+type AzureOriginRepository struct {
+	container     *container.Client
+	containerName string
+	maxSizeBytes  int64
+	timeout       time.Duration
+	log           *slog.Logger
+}
+
+func NewAzureOriginRepository(origin config.Origin, cfg *config.Config, log *slog.Logger) (*AzureOriginRepository, error) {
+	client, err := newAzureClient(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AzureOriginRepository{
+		container:     client.ServiceClient().NewContainerClient(origin.Azure.Container),
+		containerName: origin.Azure.Container,
+		maxSizeBytes:  origin.MaxSourceBytes(cfg),
+		timeout:       origin.Timeout,
+		log:           log.With(slog.String("origin", origin.Name)),
+	}, nil
+}
+
+// newAzureClient prefers a connection string, then a shared account key, and
+// falls back to the default azure credential chain.
+func newAzureClient(origin config.Origin) (*azblob.Client, error) {
+	settings := origin.Azure
+	if settings.ConnectionString != "" {
+		return azblob.NewClientFromConnectionString(settings.ConnectionString, nil)
+	}
+
+	serviceURL := settings.Endpoint
+	if serviceURL == "" {
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", settings.AccountName)
+	}
+
+	if settings.AccountKey != "" {
+		cred, err := azblob.NewSharedKeyCredential(settings.AccountName, settings.AccountKey)
+		if err != nil {
+			return nil, err
+		}
+		return azblob.NewClientWithSharedKeyCredential(serviceURL, cred, nil)
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return azblob.NewClient(serviceURL, cred, nil)
+}
+
+func (r *AzureOriginRepository) Get(ctx context.Context, imagePath string) (*domain.SourceImage, error) {
+	log := r.log.With(slog.String("imagePath", imagePath), slog.String("container", r.containerName))
+	log.Debug("fetching image from azure")
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.container.NewBlobClient(imagePath).DownloadStream(ctx, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrSourceNotFound, imagePath)
+		}
+		log.Error("failed to get blob from azure", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	defer result.Body.Close()
+
+	if contentLength := derefInt64(result.ContentLength); contentLength > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: contentLength}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(result.Body, r.maxSizeBytes+1))
+	if err != nil {
+		log.Error("failed to read blob body", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if int64(len(body)) > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: int64(len(body))}
+	}
+
+	// same scheme as s3: the version id when versioning is enabled on the
+	// account, the ETag otherwise
+	var version string
+	if result.ETag != nil {
+		version = string(*result.ETag)
+	}
+	if result.VersionID != nil && *result.VersionID != "" {
+		version = versionIDPrefix + *result.VersionID
+	}
+
+	var contentType string
+	if result.ContentType != nil {
+		contentType = *result.ContentType
+	}
+
+	log.Debug("successfully fetched image from azure", slog.Int("size_bytes", len(body)), slog.String("version", version))
+	return &domain.SourceImage{
+		Data:        body,
+		ContentType: contentType,
+		Version:     version,
+	}, nil
+}
+
+// Ping checks that the container exists and is accessible with our
+// credentials.
+func (r *AzureOriginRepository) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.container.GetProperties(ctx, nil)
+	return err
+}
+
+func (r *AzureOriginRepository) Validate(ctx context.Context, imagePath, version string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var options *blob.GetPropertiesOptions
+
+	versionID, isVersionID := strings.CutPrefix(version, versionIDPrefix)
+	if !isVersionID {
+		etag := azcore.ETag(version)
+		options = &blob.GetPropertiesOptions{
+			AccessConditions: &blob.AccessConditions{
+				ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &etag},
+			},
+		}
+	}
+
+	result, err := r.container.NewBlobClient(imagePath).GetProperties(ctx, options)
+	if err != nil {
+		var responseErr *azcore.ResponseError
+		if errors.As(err, &responseErr) {
+			switch responseErr.StatusCode {
+			case http.StatusNotModified:
+				return true, nil
+			case http.StatusNotFound:
+				return false, nil
+			}
+		}
+		return false, err
+	}
+
+	if isVersionID {
+		return result.VersionID != nil && *result.VersionID == versionID, nil
+	}
+
+	// the properties were returned despite If-None-Match, so the ETag changed
+	return false, nil
+}
+
+func derefInt64(v *int64) int64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+var _ ports.OriginRepository = (*AzureOriginRepository)(nil)
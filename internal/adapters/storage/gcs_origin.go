@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/ports"
+	"google.golang.org/api/option"
+)
+
+type GCSOriginRepository struct {
+	bucket       *storage.BucketHandle
+	bucketName   string
+	maxSizeBytes int64
+	timeout      time.Duration
+	log          *slog.Logger
+}
+
+func NewGCSOriginRepository(ctx context.Context, origin config.Origin, cfg *config.Config, log *slog.Logger) (*GCSOriginRepository, error) {
+	var opts []option.ClientOption
+	if origin.GCS.Endpoint != "" {
+		// emulators only implement the JSON API
+		opts = append(opts, option.WithEndpoint(origin.GCS.Endpoint), storage.WithJSONReads())
+	}
+	if origin.GCS.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(origin.GCS.CredentialsFile))
+	}
+	if origin.GCS.Anonymous {
+		opts = append(opts, option.WithoutAuthentication())
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSOriginRepository{
+		bucket:       client.Bucket(origin.GCS.Bucket),
+		bucketName:   origin.GCS.Bucket,
+		maxSizeBytes: origin.MaxSourceBytes(cfg),
+		timeout:      origin.Timeout,
+		log:          log.With(slog.String("origin", origin.Name)),
+	}, nil
+}
+
+// Every object has a generation that changes on each overwrite, so unlike s3
+// the version never depends on how the bucket is configured.
+const generationPrefix = "gen:"
+
+func (r *GCSOriginRepository) Get(ctx context.Context, imagePath string) (*domain.SourceImage, error) {
+	log := r.log.With(slog.String("imagePath", imagePath), slog.String("bucket", r.bucketName))
+	log.Debug("fetching image from gcs")
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	reader, err := r.bucket.Object(imagePath).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrSourceNotFound, imagePath)
+		}
+		log.Error("failed to get object from gcs", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	if size := reader.Attrs.Size; size > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: size}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, r.maxSizeBytes+1))
+	if err != nil {
+		log.Error("failed to read object body", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if int64(len(body)) > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: int64(len(body))}
+	}
+
+	version := generationPrefix + strconv.FormatInt(reader.Attrs.Generation, 10)
+
+	log.Debug("successfully fetched image from gcs", slog.Int("size_bytes", len(body)), slog.String("version", version))
+	return &domain.SourceImage{
+		Data:        body,
+		ContentType: reader.Attrs.ContentType,
+		Version:     version,
+	}, nil
+}
+
+// Ping checks that the bucket exists and is accessible with our credentials.
+func (r *GCSOriginRepository) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.bucket.Attrs(ctx)
+	return err
+}
+
+func (r *GCSOriginRepository) Validate(ctx context.Context, imagePath, version string) (bool, error) {
+	generation, ok := strings.CutPrefix(version, generationPrefix)
+	if !ok {
+		return false, nil
+	}
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	attrs, err := r.bucket.Object(imagePath).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return strconv.FormatInt(attrs.Generation, 10) == generation, nil
+}
+
+var _ ports.OriginRepository = (*GCSOriginRepository)(nil)
@@ -13,92 +13,283 @@ import (
 	"time"
 
 	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
 	"github.com/elect0/chimera/internal/ports"
 )
 
 type HTTPOriginRepository struct {
-	client *http.Client
-	cfg    *config.Config
-	log    *slog.Logger
+	client  *remoteClient
+	guard   *ssrfGuard
+	remote  config.RemoteFetch
+	headers map[string]string
+	// baseHost and baseAddr are the host and the host:port of the configured
+	// base url, if any
+	baseHost     string
+	baseAddr     string
+	timeout      time.Duration
+	maxSizeBytes int64
+	// originMaxBytes caps the size limits of host policies
+	originMaxBytes int64
+	log            *slog.Logger
 }
 
-func NewHTTPOriginRepository(cfg *config.Config, log *slog.Logger) *HTTPOriginRepository {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+func NewHTTPOriginRepository(origin config.Origin, cfg *config.Config, log *slog.Logger) (*HTTPOriginRepository, error) {
+	timeout := origin.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
 	}
 
-	return &HTTPOriginRepository{
-		client: client,
-		cfg:    cfg,
-		log:    log,
+	guard, err := newSSRFGuard(cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	r := &HTTPOriginRepository{
+		guard:          guard,
+		remote:         cfg.Security.RemoteFetch,
+		headers:        origin.HTTP.Headers,
+		timeout:        timeout,
+		maxSizeBytes:   min(int64(cfg.Security.RemoteFetch.MaxDownloadSizeMB)*1024*1024, origin.MaxSourceBytes(cfg)),
+		originMaxBytes: origin.MaxSourceBytes(cfg),
+		log:            log.With(slog.String("origin", origin.Name)),
+	}
+
+	if origin.HTTP.BaseURL != "" {
+		base, err := url.Parse(origin.HTTP.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		r.baseHost = strings.ToLower(base.Hostname())
+		r.baseAddr = dialAddr(base)
+	}
+
+	r.client = newRemoteClient(origin.Name, cfg, guard, r.checkRedirect, r.baseAddr)
+
+	return r, nil
 }
 
-func (r *HTTPOriginRepository) Get(ctx context.Context, imageURL string) ([]byte, error) {
+// dialAddr is the host:port the transport dials for u.
+func dialAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// lastModifiedPrefix marks version tokens taken from Last-Modified because the
+// remote server sent no ETag.
+const lastModifiedPrefix = "lm:"
+
+func (r *HTTPOriginRepository) Get(ctx context.Context, imageURL string) (*domain.SourceImage, error) {
 	log := r.log.With(slog.String("imageURL", imageURL))
 	log.Debug("fetching image from remote url")
 
-	if err := r.isPubliclyRoutable(imageURL); err != nil {
+	req, err := r.newRequest(ctx, http.MethodGet, imageURL)
+	if err != nil {
 		log.Warn("ssrf attempt rejected", slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
-	if err != nil {
-		log.Error("failed to create http request", slog.String("error", err.Error()))
-		return nil, err
-	}
+	timeout, maxSizeBytes := r.hostSettings(req.URL.Hostname())
+	ctx, cancel := withTimeout(req.Context(), timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
 
-	resp, err := r.client.Do(req)
+	resp, err := r.client.do(req)
 	if err != nil {
-		log.Error("failed to fetch remote url", slog.String("error", err.Error()))
+		switch {
+		case errors.Is(err, domain.ErrSourceRejected):
+			log.Warn("ssrf attempt rejected", slog.String("error", err.Error()))
+		case errors.Is(err, domain.ErrSourceUnavailable):
+			log.Debug("skipped fetch from unavailable host", slog.String("error", err.Error()))
+		default:
+			log.Error("failed to fetch remote url", slog.String("error", err.Error()))
+		}
+		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("%w: remote server returned status code %d", domain.ErrSourceNotFound, resp.StatusCode)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("remote server returned status code %d", resp.StatusCode)
 	}
 
-	maxSizeBytes := int64(r.cfg.Security.RemoteFetch.MaxDownloadSizeMB) * 1024 * 1024
 	if resp.ContentLength > maxSizeBytes {
-		return nil, fmt.Errorf("remote file size (%d bytes) exceeds max limit", resp.ContentLength)
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: maxSizeBytes, Actual: resp.ContentLength}
 	}
 
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "image/") {
-		return nil, fmt.Errorf("invalid content type '%s' must be an image", contentType)
+		return nil, fmt.Errorf("%w: invalid content type '%s' must be an image", domain.ErrSourceUnsupported, contentType)
 	}
 
-	limitedReader := &io.LimitedReader{R: resp.Body, N: maxSizeBytes}
+	limitedReader := &io.LimitedReader{R: resp.Body, N: maxSizeBytes + 1}
 	body, err := io.ReadAll(limitedReader)
 	if err != nil {
 		log.Error("failed to read response body", slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	log.Debug("successfully fetched image from remote url", slog.Int("sizes_bytes", len(body)))
-	return body, nil
+	if int64(len(body)) > maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: maxSizeBytes, Actual: int64(len(body))}
+	}
+
+	version := resp.Header.Get("ETag")
+	if version == "" && resp.Header.Get("Last-Modified") != "" {
+		version = lastModifiedPrefix + resp.Header.Get("Last-Modified")
+	}
+
+	log.Debug("successfully fetched image from remote url", slog.Int("sizes_bytes", len(body)), slog.String("version", version))
+	return &domain.SourceImage{
+		Data:        body,
+		ContentType: contentType,
+		Version:     version,
+	}, nil
 }
 
-func (r *HTTPOriginRepository) isPubliclyRoutable(imageURL string) error {
-	parsedURL, err := url.Parse(imageURL)
+func (r *HTTPOriginRepository) Validate(ctx context.Context, imageURL, version string) (bool, error) {
+	req, err := r.newRequest(ctx, http.MethodHead, imageURL)
+	if err != nil {
+		return false, err
+	}
+
+	timeout, _ := r.hostSettings(req.URL.Hostname())
+	ctx, cancel := withTimeout(req.Context(), timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	lastModified, isLastModified := strings.CutPrefix(version, lastModifiedPrefix)
+	if isLastModified {
+		req.Header.Set("If-Modified-Since", lastModified)
+	} else {
+		req.Header.Set("If-None-Match", version)
+	}
+
+	resp, err := r.client.do(req)
 	if err != nil {
-		return fmt.Errorf("invalid url: %w", err)
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("remote server returned status code %d", resp.StatusCode)
 	}
 
-	ips, err := net.LookupIP(parsedURL.Hostname())
+	// servers that ignore conditional headers on HEAD still report the validators
+	if isLastModified {
+		return resp.Header.Get("Last-Modified") == lastModified, nil
+	}
+	return resp.Header.Get("ETag") == version, nil
+}
+
+// newRequest builds a request for imageURL with the configured headers. The
+// url is checked against the allowed schemes here, the address it resolves to
+// only when connecting. Hosts of client urls are checked by the router, as
+// the base url of an origin is never subject to the host allowlist. Requests
+// for the base url address may connect to it without the address checks.
+func (r *HTTPOriginRepository) newRequest(ctx context.Context, method, imageURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, imageURL, nil)
 	if err != nil {
-		return fmt.Errorf("dns lookup failed: %w", err)
+		return nil, fmt.Errorf("%w: invalid url: %w", domain.ErrSourceRejected, err)
+	}
+
+	if err := r.guard.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	if r.baseAddr != "" && strings.EqualFold(dialAddr(req.URL), r.baseAddr) {
+		req = req.WithContext(withTrustedDial(ctx))
+	}
+
+	for name, value := range r.headers {
+		req.Header.Set(name, value)
+	}
+	r.setHostHeaders(req)
+
+	return req, nil
+}
+
+// setHostHeaders adds the headers and credentials of the policy matching the
+// host of req, such as a token for a private partner cdn.
+func (r *HTTPOriginRepository) setHostHeaders(req *http.Request) {
+	policy, ok := r.remote.Policy(req.URL.Hostname())
+	if !ok {
+		return
+	}
+
+	for name, value := range policy.Headers {
+		req.Header.Set(name, value)
 	}
 
-	for _, ip := range ips {
-		if ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalMulticast() || ip.IsLinkLocalUnicast() {
-			return errors.New("url resolves to a non-public ip address")
+	switch policy.Auth.Type {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+policy.Auth.Token)
+	case "basic":
+		req.SetBasicAuth(policy.Auth.Username, policy.Auth.Password)
+	}
+}
+
+// hostSettings returns the timeout and size limit for host, taken from its
+// policy when it has one. A policy can raise the remote download limit but
+// never above the limit of the origin.
+func (r *HTTPOriginRepository) hostSettings(host string) (time.Duration, int64) {
+	timeout, maxSizeBytes := r.timeout, r.maxSizeBytes
+
+	if policy, ok := r.remote.Policy(host); ok {
+		if policy.Timeout > 0 {
+			timeout = policy.Timeout
+		}
+		if policy.MaxDownloadSizeMB > 0 {
+			maxSizeBytes = min(int64(policy.MaxDownloadSizeMB)*1024*1024, r.originMaxBytes)
+		}
+	}
+
+	return timeout, maxSizeBytes
+}
+
+// checkRedirect applies the url and host checks to every hop, except for the
+// host allowlist on hops staying on the base url host. Headers set for the
+// first host are dropped when a redirect leaves it, so credentials never
+// reach another host.
+func (r *HTTPOriginRepository) checkRedirect(req *http.Request, via []*http.Request) error {
+	if err := r.guard.checkRedirect(req, via); err != nil {
+		return err
+	}
+	if !r.isBaseHost(req.URL.Hostname()) && !r.remote.HostAllowed(req.URL.Hostname()) {
+		return &domain.HostError{Host: req.URL.Hostname()}
+	}
+
+	if first := via[0].URL.Hostname(); req.URL.Hostname() != first {
+		for name := range r.headers {
+			req.Header.Del(name)
+		}
+		if policy, ok := r.remote.Policy(first); ok {
+			for name := range policy.Headers {
+				req.Header.Del(name)
+			}
 		}
+		req.Header.Del("Authorization")
+		r.setHostHeaders(req)
 	}
 
 	return nil
 }
 
+func (r *HTTPOriginRepository) isBaseHost(host string) bool {
+	return r.baseHost != "" && strings.EqualFold(host, r.baseHost)
+}
+
 var _ ports.OriginRepository = (*HTTPOriginRepository)(nil)
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+)
+
+func newTestHTTPOrigin(t *testing.T, baseURL string) *HTTPOriginRepository {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Limits.MaxSourceSizeMB = 1
+	remote := &cfg.Security.RemoteFetch
+	remote.MaxDownloadSizeMB = 1
+	remote.AllowedSchemes = []string{"http", "https"}
+	remote.MaxRedirects = 3
+	remote.Retry.MaxAttempts = 1
+	remote.CircuitBreaker.FailureThreshold = 5
+	remote.MaxConcurrentPerHost = 4
+
+	origin := config.Origin{Name: "internal", Type: "http"}
+	origin.HTTP.BaseURL = baseURL
+
+	repo, err := NewHTTPOriginRepository(origin, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func serveImage(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Write([]byte("\x89PNG\r\n\x1a\n"))
+}
+
+func TestHTTPOriginTrustsOnlyTheBaseAddress(t *testing.T) {
+	// both servers listen on loopback, which the guard rejects unless the
+	// connection goes to the exact base url address
+	other := httptest.NewServer(http.HandlerFunc(serveImage))
+	defer other.Close()
+
+	base := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, other.URL+"/image.png", http.StatusFound)
+			return
+		}
+		serveImage(w, r)
+	}))
+	defer base.Close()
+
+	repo := newTestHTTPOrigin(t, base.URL)
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{"base url address", base.URL + "/image.png", nil},
+		{"other port on the base host", other.URL + "/image.png", domain.ErrSourceRejected},
+		{"redirect to another port on the base host", base.URL + "/redirect", domain.ErrSourceRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.Get(context.Background(), tt.url)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Get(%s) = %v, want no error", tt.url, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get(%s) = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+// LocalOriginRepository serves source images from a directory. Paths are
+// resolved through an os.Root, so neither ".." nor symlinks can reach files
+// outside of it, and the symlink policy decides whether links inside the root
+// are followed at all.
+type LocalOriginRepository struct {
+	root         *os.Root
+	symlinks     string
+	maxSizeBytes int64
+	log          *slog.Logger
+}
+
+func NewLocalOriginRepository(origin config.Origin, cfg *config.Config, log *slog.Logger) (*LocalOriginRepository, error) {
+	root, err := os.OpenRoot(origin.Local.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalOriginRepository{
+		root:         root,
+		symlinks:     origin.Local.Symlinks,
+		maxSizeBytes: origin.MaxSourceBytes(cfg),
+		log:          log.With(slog.String("origin", origin.Name), slog.String("root", origin.Local.Root)),
+	}, nil
+}
+
+func (r *LocalOriginRepository) Get(ctx context.Context, imagePath string) (*domain.SourceImage, error) {
+	name, err := r.resolve(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// opening a fifo or device blocks, so only regular files are opened, and
+	// without blocking in case the file is swapped in between
+	info, err := r.root.Stat(name)
+	if err != nil {
+		return nil, r.openError(imagePath, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", domain.ErrSourceNotFound, imagePath)
+	}
+
+	file, err := r.root.OpenFile(name, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, r.openError(imagePath, err)
+	}
+	defer file.Close()
+
+	info, err = file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", domain.ErrSourceNotFound, imagePath)
+	}
+
+	if info.Size() > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: info.Size()}
+	}
+
+	// the file may grow between Stat and reading it
+	data, err := io.ReadAll(io.LimitReader(file, r.maxSizeBytes+1))
+	if err != nil {
+		r.log.Error("failed to read source file", slog.String("path", imagePath), slog.String("error", err.Error()))
+		return nil, err
+	}
+	if int64(len(data)) > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: int64(len(data))}
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	return &domain.SourceImage{
+		Data:        data,
+		ContentType: contentType,
+		Version:     fileVersion(info),
+	}, nil
+}
+
+func (r *LocalOriginRepository) Validate(ctx context.Context, imagePath, version string) (bool, error) {
+	name, err := r.resolve(imagePath)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := r.root.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return fileVersion(info) == version, nil
+}
+
+// Ping checks that the root directory is still there.
+func (r *LocalOriginRepository) Ping(ctx context.Context) error {
+	_, err := r.root.Stat(".")
+	return err
+}
+
+// resolve turns a request path into a name within the root, rejecting
+// anything that isn't a plain relative path and, with the "deny" policy, any
+// path going through a symlink.
+func (r *LocalOriginRepository) resolve(imagePath string) (string, error) {
+	name := strings.TrimPrefix(imagePath, "/")
+	if name == "" || strings.ContainsRune(name, 0) || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("%w: invalid path '%s'", domain.ErrSourceRejected, imagePath)
+	}
+
+	if r.symlinks == "deny" {
+		parts := strings.Split(name, "/")
+		for i := range parts {
+			info, err := r.root.Lstat(strings.Join(parts[:i+1], "/"))
+			if err != nil {
+				// missing components are reported by the caller
+				break
+			}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				return "", fmt.Errorf("%w: '%s' goes through a symlink", domain.ErrSourceRejected, imagePath)
+			}
+		}
+	}
+
+	return name, nil
+}
+
+func (r *LocalOriginRepository) openError(imagePath string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", domain.ErrSourceNotFound, imagePath)
+	}
+	// os.Root doesn't export the error it returns for escaping symlinks
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && strings.Contains(pathErr.Err.Error(), "path escapes") {
+		return fmt.Errorf("%w: '%s' leaves the origin root", domain.ErrSourceRejected, imagePath)
+	}
+	return err
+}
+
+// fileVersion changes whenever a file is rewritten, the same way web servers
+// derive an ETag from the modification time and size.
+func fileVersion(info fs.FileInfo) string {
+	return fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+}
+
+var _ ports.OriginRepository = (*LocalOriginRepository)(nil)
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+)
+
+func newTestLocalOrigin(t *testing.T, symlinks string) *LocalOriginRepository {
+	t.Helper()
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+
+	writeFile(t, filepath.Join(dir, "outside.png"))
+	writeFile(t, filepath.Join(root, "image.png"))
+	writeFile(t, filepath.Join(root, "dir", "nested.png"))
+	symlink(t, filepath.Join("..", "outside.png"), filepath.Join(root, "escape.png"))
+	symlink(t, "..", filepath.Join(root, "parent"))
+	symlink(t, "image.png", filepath.Join(root, "link.png"))
+	symlink(t, "dir", filepath.Join(root, "linkdir"))
+
+	cfg := &config.Config{}
+	cfg.Limits.MaxSourceSizeMB = 1
+
+	origin := config.Origin{Name: "local"}
+	origin.Local.Root = root
+	origin.Local.Symlinks = symlinks
+
+	repo, err := NewLocalOriginRepository(origin, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.root.Close() })
+	return repo
+}
+
+func writeFile(t *testing.T, name string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte("\x89PNG\r\n\x1a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, name string) {
+	t.Helper()
+	if err := os.Symlink(target, name); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+func TestLocalOriginGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		symlinks string
+		path     string
+		wantErr  error
+	}{
+		{"plain file", "within_root", "/image.png", nil},
+		{"nested file", "within_root", "/dir/nested.png", nil},
+		{"missing file", "within_root", "/missing.png", domain.ErrSourceNotFound},
+		{"directory", "within_root", "/dir", domain.ErrSourceNotFound},
+		{"empty path", "within_root", "/", domain.ErrSourceRejected},
+		{"parent directory", "within_root", "/../outside.png", domain.ErrSourceRejected},
+		{"parent in the middle", "within_root", "/dir/../../outside.png", domain.ErrSourceRejected},
+		{"absolute path", "within_root", "//etc/passwd", domain.ErrSourceRejected},
+		{"nul byte", "within_root", "/image.png\x00.jpg", domain.ErrSourceRejected},
+		{"symlink inside root", "within_root", "/link.png", nil},
+		{"symlinked directory inside root", "within_root", "/linkdir/nested.png", nil},
+		{"symlink escaping root", "within_root", "/escape.png", domain.ErrSourceRejected},
+		{"symlinked parent escaping root", "within_root", "/parent/outside.png", domain.ErrSourceRejected},
+		{"deny plain file", "deny", "/dir/nested.png", nil},
+		{"deny symlink inside root", "deny", "/link.png", domain.ErrSourceRejected},
+		{"deny symlinked directory", "deny", "/linkdir/nested.png", domain.ErrSourceRejected},
+		{"deny symlink escaping root", "deny", "/escape.png", domain.ErrSourceRejected},
+		{"deny missing file", "deny", "/missing.png", domain.ErrSourceNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestLocalOrigin(t, tt.symlinks)
+
+			src, err := repo.Get(context.Background(), tt.path)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Get(%q) = %v, want no error", tt.path, err)
+				}
+				if src.Version == "" || len(src.Data) == 0 {
+					t.Fatalf("Get(%q) returned an empty source", tt.path)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get(%q) = %v, want %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalOriginValidate(t *testing.T) {
+	repo := newTestLocalOrigin(t, "within_root")
+	ctx := context.Background()
+
+	src, err := repo.Get(ctx, "/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := repo.Validate(ctx, "/image.png", src.Version); err != nil || !ok {
+		t.Fatalf("Validate with the current version = %v, %v, want true", ok, err)
+	}
+	if ok, err := repo.Validate(ctx, "/image.png", "stale"); err != nil || ok {
+		t.Fatalf("Validate with a stale version = %v, %v, want false", ok, err)
+	}
+	if ok, err := repo.Validate(ctx, "/missing.png", src.Version); err != nil || ok {
+		t.Fatalf("Validate of a missing file = %v, %v, want false", ok, err)
+	}
+	if _, err := repo.Validate(ctx, "/../outside.png", src.Version); !errors.Is(err, domain.ErrSourceRejected) {
+		t.Fatalf("Validate outside the root = %v, want %v", err, domain.ErrSourceRejected)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/elect0/chimera/internal/domain"
+)
+
+func TestLocalOriginGetFIFO(t *testing.T) {
+	repo := newTestLocalOrigin(t, "within_root")
+
+	if err := syscall.Mkfifo(filepath.Join(repo.root.Name(), "pipe.png"), 0o644); err != nil {
+		t.Skipf("fifos not supported: %v", err)
+	}
+	symlink(t, "pipe.png", filepath.Join(repo.root.Name(), "pipe-link.png"))
+
+	for _, path := range []string{"/pipe.png", "/pipe-link.png"} {
+		done := make(chan error, 1)
+		go func() {
+			_, err := repo.Get(context.Background(), path)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, domain.ErrSourceNotFound) {
+				t.Fatalf("Get(%q) = %v, want %v", path, err, domain.ErrSourceNotFound)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Get(%q) blocked on a fifo", path)
+		}
+	}
+}
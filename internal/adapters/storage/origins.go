@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+// NewOrigins builds a repository for every configured origin, keyed by name.
+func NewOrigins(ctx context.Context, cfg *config.Config, log *slog.Logger) (map[string]ports.OriginRepository, error) {
+	origins := make(map[string]ports.OriginRepository, len(cfg.Origins))
+
+	for _, origin := range cfg.Origins {
+		switch origin.Type {
+		case "s3":
+			repo, err := NewS3OriginRepository(ctx, origin, cfg, log)
+			if err != nil {
+				return nil, fmt.Errorf("origin '%s': %w", origin.Name, err)
+			}
+			origins[origin.Name] = repo
+		case "gcs":
+			repo, err := NewGCSOriginRepository(ctx, origin, cfg, log)
+			if err != nil {
+				return nil, fmt.Errorf("origin '%s': %w", origin.Name, err)
+			}
+			origins[origin.Name] = repo
+		case "azure":
+			repo, err := NewAzureOriginRepository(origin, cfg, log)
+			if err != nil {
+				return nil, fmt.Errorf("origin '%s': %w", origin.Name, err)
+			}
+			origins[origin.Name] = repo
+		case "http":
+			repo, err := NewHTTPOriginRepository(origin, cfg, log)
+			if err != nil {
+				return nil, fmt.Errorf("origin '%s': %w", origin.Name, err)
+			}
+			origins[origin.Name] = repo
+		case "local":
+			repo, err := NewLocalOriginRepository(origin, cfg, log)
+			if err != nil {
+				return nil, fmt.Errorf("origin '%s': %w", origin.Name, err)
+			}
+			origins[origin.Name] = repo
+		default:
+			return nil, fmt.Errorf("origin '%s': unknown type '%s'", origin.Name, origin.Type)
+		}
+	}
+
+	return origins, nil
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elect0/chimera/internal/circuitbreaker"
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/lru"
+	"github.com/elect0/chimera/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxTrackedHosts bounds the breakers and concurrency limits kept per host,
+// the least recently used host is forgotten first.
+const maxTrackedHosts = 1024
+
+type remoteHost struct {
+	breaker *circuitbreaker.Breaker
+	slots   chan struct{}
+	// requests using the host, guarded by remoteClient.mu
+	refs int
+}
+
+// remoteClient fetches remote urls over a pooled transport guarded against
+// SSRF. Idempotent requests that fail transiently are retried with jittered
+// backoff, and every host gets its own circuit breaker and concurrency limit
+// so one slow or failing host can't hold up fetches from the others.
+type remoteClient struct {
+	client        *http.Client
+	name          string
+	retry         retrySettings
+	breakerOpts   circuitbreaker.Options
+	maxConcurrent int
+
+	mu    sync.Mutex
+	hosts *lru.Cache[*remoteHost]
+	// hosts evicted while requests still use them, kept until the last one is
+	// done so their breaker and concurrency limit stay in force
+	busy map[string]*remoteHost
+}
+
+type retrySettings struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+type trustedDialKey struct{}
+
+// withTrustedDial marks requests built from the base url of an origin.
+func withTrustedDial(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trustedDialKey{}, true)
+}
+
+// newRemoteClient builds the client of an http origin. Connections of marked
+// requests to trustedAddr, the host:port of the base url set by the operator,
+// skip the address checks, so an origin can point at an internal image
+// service.
+func newRemoteClient(name string, cfg *config.Config, guard *ssrfGuard, checkRedirect func(*http.Request, []*http.Request) error, trustedAddr string) *remoteClient {
+	remote := cfg.Security.RemoteFetch
+
+	trustedDialer := &net.Dialer{
+		Timeout:   remote.Transport.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	guarded := &net.Dialer{
+		Timeout:   remote.Transport.DialTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   guard.control,
+	}
+
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if marked, _ := ctx.Value(trustedDialKey{}).(bool); marked && trustedAddr != "" && strings.EqualFold(address, trustedAddr) {
+			return trustedDialer.DialContext(ctx, network, address)
+		}
+		return guarded.DialContext(ctx, network, address)
+	}
+
+	// no proxy, as the dialer would only see the address of the proxy
+	// instead of the one of the host
+	transport := &http.Transport{
+		DialContext:           dial,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   remote.Transport.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: remote.Transport.ResponseHeaderTimeout,
+		IdleConnTimeout:       remote.Transport.IdleConnTimeout,
+		MaxIdleConns:          remote.Transport.MaxIdleConns,
+		MaxIdleConnsPerHost:   remote.Transport.MaxIdleConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	c := &remoteClient{
+		// timeouts are set per request as they depend on the host
+		client: &http.Client{
+			Transport:     transport,
+			CheckRedirect: checkRedirect,
+		},
+		name: name,
+		retry: retrySettings{
+			maxAttempts: remote.Retry.MaxAttempts,
+			baseDelay:   remote.Retry.BaseDelay,
+			maxDelay:    remote.Retry.MaxDelay,
+		},
+		breakerOpts: circuitbreaker.Options{
+			FailureThreshold: remote.CircuitBreaker.FailureThreshold,
+			OpenTimeout:      remote.CircuitBreaker.OpenTimeout,
+		},
+		maxConcurrent: remote.MaxConcurrentPerHost,
+		hosts:         lru.New[*remoteHost](maxTrackedHosts),
+		busy:          make(map[string]*remoteHost),
+	}
+
+	// evictions happen in acquire, with c.mu held
+	c.hosts.OnEvict(func(name string, host *remoteHost) {
+		if host.refs > 0 {
+			c.busy[name] = host
+			return
+		}
+		forgetHost(host)
+	})
+
+	return c
+}
+
+// acquire returns the host state for name and marks it in use until release.
+func (c *remoteClient) acquire(name string) *remoteHost {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host, ok := c.hosts.Get(name)
+	if !ok {
+		if host, ok = c.busy[name]; ok {
+			delete(c.busy, name)
+		} else {
+			host = &remoteHost{
+				breaker: circuitbreaker.New("remote:"+c.name+":"+name, c.breakerOpts),
+				slots:   make(chan struct{}, c.maxConcurrent),
+			}
+		}
+		c.hosts.Set(name, host, 1, 0)
+	}
+
+	host.refs++
+	return host
+}
+
+func (c *remoteClient) release(name string, host *remoteHost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host.refs--
+	if host.refs == 0 && c.busy[name] == host {
+		delete(c.busy, name)
+		forgetHost(host)
+	}
+}
+
+func forgetHost(host *remoteHost) {
+	metrics.CircuitBreakerState.DeletePartialMatch(prometheus.Labels{"name": host.breaker.Name()})
+	metrics.CircuitBreakerTransitionsTotal.DeletePartialMatch(prometheus.Labels{"name": host.breaker.Name()})
+}
+
+// do sends req, which must have no body. The response body holds a
+// concurrency slot of the host until it is closed.
+func (c *remoteClient) do(req *http.Request) (*http.Response, error) {
+	name := req.URL.Hostname()
+	host := c.acquire(name)
+
+	resp, err := c.send(req, host)
+	if resp == nil {
+		c.release(name, host)
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: sync.OnceFunc(func() { c.release(name, host) })}
+	return resp, err
+}
+
+func (c *remoteClient) send(req *http.Request, host *remoteHost) (*http.Response, error) {
+	ctx := req.Context()
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	for attempt := 1; ; attempt++ {
+		resp, outcome, err := c.attempt(req, host)
+
+		if attempt >= c.retry.maxAttempts || !idempotent || !retryable(outcome) {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt, resp)
+		if resp != nil {
+			// drain a little so the connection can be reused
+			io.CopyN(io.Discard, resp.Body, 4096)
+			resp.Body.Close()
+		}
+
+		metrics.RemoteFetchRetriesTotal.WithLabelValues(outcome).Inc()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if resp != nil {
+				return nil, fmt.Errorf("remote server returned status code %d, gave up retrying: %w", resp.StatusCode, ctx.Err())
+			}
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *remoteClient) attempt(req *http.Request, host *remoteHost) (*http.Response, string, error) {
+	start := time.Now()
+	record := func(outcome string) {
+		metrics.RemoteFetchTotal.WithLabelValues(req.Method, outcome).Inc()
+		metrics.RemoteFetchDuration.WithLabelValues(req.Method, outcome).Observe(time.Since(start).Seconds())
+	}
+
+	select {
+	case host.slots <- struct{}{}:
+	case <-req.Context().Done():
+		record("concurrency_limited")
+		return nil, "concurrency_limited", fmt.Errorf("waiting for a connection to '%s': %w", req.URL.Hostname(), req.Context().Err())
+	}
+	release := sync.OnceFunc(func() { <-host.slots })
+
+	if !host.breaker.Allow() {
+		release()
+		record("breaker_open")
+		return nil, "breaker_open", fmt.Errorf("%w: too many failures from '%s'", domain.ErrSourceUnavailable, req.URL.Hostname())
+	}
+
+	resp, err := c.client.Do(req)
+	outcome := classify(req.Context(), resp, err)
+	record(outcome)
+
+	switch outcome {
+	case "network_error", "timeout", "server_error", "throttled":
+		host.breaker.Failure()
+	case "ok", "not_found", "client_error":
+		host.breaker.Success()
+	}
+	// rejected and canceled requests say nothing about the host
+
+	if err != nil {
+		release()
+		return nil, outcome, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, outcome, nil
+}
+
+func classify(ctx context.Context, resp *http.Response, err error) string {
+	if err != nil {
+		var netErr net.Error
+		switch {
+		case errors.Is(err, domain.ErrSourceRejected):
+			return "rejected"
+		case errors.Is(ctx.Err(), context.Canceled):
+			return "canceled"
+		case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+			return "timeout"
+		}
+		return "network_error"
+	}
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusTooManyRequests:
+		return "throttled"
+	case code >= 500:
+		return "server_error"
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return "not_found"
+	case code >= 400:
+		return "client_error"
+	}
+	return "ok"
+}
+
+func retryable(outcome string) bool {
+	switch outcome {
+	case "network_error", "timeout", "server_error", "throttled":
+		return true
+	}
+	return false
+}
+
+// backoff waits a random delay up to an exponentially growing cap, or as long
+// as the host asks through Retry-After when that's below the maximum delay.
+func (c *remoteClient) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			if delay := time.Duration(seconds) * time.Second; delay <= c.retry.maxDelay {
+				return delay
+			}
+		}
+	}
+
+	// stop doubling at maxDelay, a plain shift overflows after enough attempts
+	ceiling := min(c.retry.baseDelay, c.retry.maxDelay)
+	for range attempt - 1 {
+		if ceiling > c.retry.maxDelay/2 {
+			ceiling = c.retry.maxDelay
+			break
+		}
+		ceiling *= 2
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
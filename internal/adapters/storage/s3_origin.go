@@ -2,60 +2,143 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/elect0/chimera/internal/adapters/s3client"
 	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
 	"github.com/elect0/chimera/internal/ports"
 )
 
 type S3OriginRepository struct {
-	s3Client   *s3.Client
-	bucketName string
-	log        *slog.Logger
+	s3Client     *s3.Client
+	bucketName   string
+	maxSizeBytes int64
+	timeout      time.Duration
+	log          *slog.Logger
 }
 
-func NewS3OriginRepository(ctx context.Context, cfg *config.Config, log *slog.Logger) (*S3OriginRepository, error) {
-	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.S3.Region))
+func NewS3OriginRepository(ctx context.Context, origin config.Origin, cfg *config.Config, log *slog.Logger) (*S3OriginRepository, error) {
+	s3Client, err := s3client.New(ctx, origin.S3.S3Client)
 	if err != nil {
 		return nil, err
 	}
 
-	s3Client := s3.NewFromConfig(awsCfg)
-
 	return &S3OriginRepository{
-		s3Client:   s3Client,
-		bucketName: cfg.S3.Bucket,
-		log:        log,
+		s3Client:     s3Client,
+		bucketName:   origin.S3.Bucket,
+		maxSizeBytes: origin.MaxSourceBytes(cfg),
+		timeout:      origin.Timeout,
+		log:          log.With(slog.String("origin", origin.Name)),
 	}, nil
 }
 
-func (r *S3OriginRepository) Get(ctx context.Context, imagePath string) ([]byte, error) {
+// versionIDPrefix marks version tokens taken from a versioned bucket, all
+// other tokens are object ETags.
+const versionIDPrefix = "vid:"
+
+func (r *S3OriginRepository) Get(ctx context.Context, imagePath string) (*domain.SourceImage, error) {
 	log := r.log.With(slog.String("imagePath", imagePath), slog.String("bucket", r.bucketName))
 	log.Debug("fetching image from s3")
 
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	result, err := r.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(r.bucketName),
 		Key:    aws.String(imagePath),
 	})
 	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrSourceNotFound, imagePath)
+		}
 		log.Error("failed to get object from s3", slog.String("error", err.Error()))
 		return nil, err
 	}
 
 	defer result.Body.Close()
 
-	body, err := io.ReadAll(result.Body)
+	if contentLength := aws.ToInt64(result.ContentLength); contentLength > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: contentLength}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(result.Body, r.maxSizeBytes+1))
 	if err != nil {
 		log.Error("failed to read object body", slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	log.Debug("successfully fetched image from s3", slog.Int("size_bytes", len(body)))
-	return body, nil
+	if int64(len(body)) > r.maxSizeBytes {
+		return nil, &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: r.maxSizeBytes, Actual: int64(len(body))}
+	}
+
+	version := aws.ToString(result.ETag)
+	if versionID := aws.ToString(result.VersionId); versionID != "" && versionID != "null" {
+		version = versionIDPrefix + versionID
+	}
+
+	log.Debug("successfully fetched image from s3", slog.Int("size_bytes", len(body)), slog.String("version", version))
+	return &domain.SourceImage{
+		Data:        body,
+		ContentType: aws.ToString(result.ContentType),
+		Version:     version,
+	}, nil
+}
+
+// Ping checks that the bucket exists and is accessible with our credentials.
+func (r *S3OriginRepository) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(r.bucketName)})
+	return err
+}
+
+func (r *S3OriginRepository) Validate(ctx context.Context, imagePath, version string) (bool, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(r.bucketName),
+		Key:    aws.String(imagePath),
+	}
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	versionID, isVersionID := strings.CutPrefix(version, versionIDPrefix)
+	if !isVersionID {
+		input.IfNoneMatch = aws.String(version)
+	}
+
+	result, err := r.s3Client.HeadObject(ctx, input)
+	if err != nil {
+		var responseErr *awshttp.ResponseError
+		if errors.As(err, &responseErr) {
+			switch responseErr.HTTPStatusCode() {
+			case http.StatusNotModified:
+				return true, nil
+			case http.StatusNotFound:
+				return false, nil
+			}
+		}
+		return false, err
+	}
+
+	if isVersionID {
+		return aws.ToString(result.VersionId) == versionID, nil
+	}
+
+	// the object was returned despite If-None-Match, so the ETag changed
+	return false, nil
 }
 
 var _ ports.OriginRepository = (*S3OriginRepository)(nil)
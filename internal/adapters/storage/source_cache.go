@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/lru"
+	"github.com/elect0/chimera/internal/metrics"
+	"github.com/elect0/chimera/internal/ports"
+	"github.com/h2non/bimg"
+)
+
+// CachedOriginRepository keeps recently fetched originals of an origin, so
+// rendering several variants of one source downloads it only once. Sources are
+// kept in a CacheRepository as variants without dimensions, and an entry older
+// than revalidateAfter is only reused once the origin confirms its version.
+type CachedOriginRepository struct {
+	name            string
+	origin          ports.OriginRepository
+	store           ports.CacheRepository
+	maxObjectSize   int64
+	ttl             time.Duration
+	revalidateAfter time.Duration
+	confirmed       *lru.Cache[struct{}]
+	log             *slog.Logger
+}
+
+func NewCachedOriginRepository(name string, origin ports.OriginRepository, store ports.CacheRepository, cfg *config.Config, log *slog.Logger) *CachedOriginRepository {
+	return &CachedOriginRepository{
+		name:            name,
+		origin:          origin,
+		store:           store,
+		maxObjectSize:   int64(cfg.Cache.Source.MaxObjectMB) * 1024 * 1024,
+		ttl:             cfg.Cache.Source.TTL,
+		revalidateAfter: cfg.Cache.Source.RevalidateAfter,
+		confirmed:       lru.New[struct{}](int64(cfg.Cache.Revalidation.MaxConfirmed)),
+		log:             log.With(slog.String("cache", "source"), slog.String("origin", name)),
+	}
+}
+
+func (r *CachedOriginRepository) Get(ctx context.Context, imagePath string) (*domain.SourceImage, error) {
+	key := r.key(imagePath)
+
+	cached, err := r.store.Get(ctx, key)
+	if err != nil && !errors.Is(err, domain.ErrCacheMiss) {
+		r.log.Error("error getting source from cache", slog.String("path", imagePath), slog.String("error", err.Error()))
+	}
+
+	if cached != nil && r.reusable(ctx, imagePath, cached) {
+		metrics.SourceCacheHitsTotal.WithLabelValues(r.name).Inc()
+		return &domain.SourceImage{
+			Data:        cached.Data,
+			ContentType: cached.ContentType(),
+			Version:     cached.SourceVersion,
+		}, nil
+	}
+	metrics.SourceCacheMissesTotal.WithLabelValues(r.name).Inc()
+
+	source, err := r.origin.Get(ctx, imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(source.Data)) > r.maxObjectSize {
+		metrics.SourceCacheSkippedTotal.WithLabelValues(r.name).Inc()
+		return source, nil
+	}
+
+	entry := domain.NewVariant(source.Data, bimg.DetermineImageType(source.Data), 0, 0, source.Version)
+	entry.ExpiresAt = entry.CreatedAt.Add(r.ttl)
+
+	if err := r.store.Set(ctx, key, entry); err != nil {
+		r.log.Error("failed to cache source", slog.String("path", imagePath), slog.String("error", err.Error()))
+	} else if source.Version != "" {
+		r.confirmed.Set(imagePath+"@"+source.Version, struct{}{}, 1, r.revalidateAfter)
+	}
+
+	return source, nil
+}
+
+func (r *CachedOriginRepository) Validate(ctx context.Context, imagePath, version string) (bool, error) {
+	return r.origin.Validate(ctx, imagePath, version)
+}
+
+// reusable reports whether a cached source may be used without downloading it
+// again. Entries without a version can't be revalidated and are only trusted
+// until revalidateAfter has passed.
+func (r *CachedOriginRepository) reusable(ctx context.Context, imagePath string, cached *domain.Variant) bool {
+	if cached.Age() <= r.revalidateAfter {
+		return true
+	}
+	if cached.SourceVersion == "" {
+		return false
+	}
+
+	confirmedKey := imagePath + "@" + cached.SourceVersion
+	if _, ok := r.confirmed.Get(confirmedKey); ok {
+		return true
+	}
+
+	current, err := r.origin.Validate(ctx, imagePath, cached.SourceVersion)
+	if err != nil {
+		r.log.Warn("failed to revalidate cached source", slog.String("path", imagePath), slog.String("error", err.Error()))
+		return false
+	}
+	if !current {
+		return false
+	}
+
+	r.confirmed.Set(confirmedKey, struct{}{}, 1, r.revalidateAfter)
+	return true
+}
+
+// Invalidate drops the cached copy of a source, so the next request downloads
+// it again.
+func (r *CachedOriginRepository) Invalidate(ctx context.Context, imagePath string) error {
+	_, err := r.store.Delete(ctx, r.key(imagePath))
+	return err
+}
+
+func (r *CachedOriginRepository) key(imagePath string) string {
+	return "source:" + r.name + ":" + imagePath
+}
+
+var (
+	_ ports.OriginRepository  = (*CachedOriginRepository)(nil)
+	_ ports.SourceInvalidator = (*CachedOriginRepository)(nil)
+)
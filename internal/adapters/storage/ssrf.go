@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"syscall"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+)
+
+// reservedPrefixes are never fetched from unless allowed explicitly: private,
+// loopback, link-local, CGNAT, documentation, multicast and other special
+// purpose ranges, plus the IPv6 ranges that embed an IPv4 address.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// ssrfGuard decides which urls and addresses remote fetches may reach. The
+// address check runs in the dialer on the ip actually being connected to, so
+// a hostname can't resolve to a public ip when checked and a private one when
+// fetched, and every redirect hop goes through it as well.
+type ssrfGuard struct {
+	schemes      []string
+	deny         []netip.Prefix
+	allow        []netip.Prefix
+	maxRedirects int
+}
+
+func newSSRFGuard(cfg *config.Config) (*ssrfGuard, error) {
+	remote := cfg.Security.RemoteFetch
+
+	g := &ssrfGuard{
+		schemes:      remote.AllowedSchemes,
+		deny:         slices.Clone(reservedPrefixes),
+		maxRedirects: remote.MaxRedirects,
+	}
+
+	for _, cidr := range remote.DenyCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		g.deny = append(g.deny, prefix)
+	}
+
+	for _, cidr := range remote.AllowCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		g.allow = append(g.allow, prefix)
+	}
+
+	return g, nil
+}
+
+func (g *ssrfGuard) checkURL(u *url.URL) error {
+	if !slices.Contains(g.schemes, u.Scheme) {
+		return fmt.Errorf("%w: scheme '%s' is not allowed", domain.ErrSourceRejected, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: url has no host", domain.ErrSourceRejected)
+	}
+	return nil
+}
+
+func (g *ssrfGuard) checkAddr(addr netip.Addr) error {
+	// ::ffff:10.0.0.1 reaches 10.0.0.1
+	addr = addr.Unmap().WithZone("")
+
+	for _, prefix := range g.allow {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	for _, prefix := range g.deny {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s is not a public address", domain.ErrSourceRejected, addr)
+		}
+	}
+
+	return nil
+}
+
+// control is a net.Dialer hook, called after name resolution with the ip
+// and port of every connection attempt.
+func (g *ssrfGuard) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: unexpected dial address '%s'", domain.ErrSourceRejected, address)
+	}
+	return g.checkAddr(addrPort.Addr())
+}
+
+func (g *ssrfGuard) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > g.maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", domain.ErrSourceRejected, g.maxRedirects)
+	}
+	return g.checkURL(req.URL)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+)
+
+func TestSSRFGuardCheckAddr(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.RemoteFetch.DenyCIDRs = []string{"93.184.216.0/24"}
+	cfg.Security.RemoteFetch.AllowCIDRs = []string{"10.1.2.0/24"}
+
+	guard, err := newSSRFGuard(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		addr    string
+		allowed bool
+	}{
+		{"public ipv4", "8.8.8.8", true},
+		{"public ipv6", "2606:4700:4700::1111", true},
+		{"this network", "0.0.0.0", false},
+		{"this network range", "0.1.2.3", false},
+		{"private 10/8", "10.0.0.1", false},
+		{"private 172.16/12", "172.31.255.255", false},
+		{"private 192.168/16", "192.168.1.1", false},
+		{"loopback", "127.0.0.1", false},
+		{"cgnat", "100.64.0.1", false},
+		{"cgnat upper bound", "100.127.255.255", false},
+		{"just above cgnat", "100.128.0.1", true},
+		{"link-local", "169.254.169.254", false},
+		{"documentation", "192.0.2.10", false},
+		{"benchmarking", "198.18.0.1", false},
+		{"multicast", "224.0.0.1", false},
+		{"reserved", "240.0.0.1", false},
+		{"broadcast", "255.255.255.255", false},
+		{"ipv4-mapped loopback", "::ffff:127.0.0.1", false},
+		{"ipv4-mapped private", "::ffff:10.0.0.1", false},
+		{"ipv4-mapped public", "::ffff:8.8.8.8", true},
+		{"ipv6 unspecified", "::", false},
+		{"ipv6 loopback", "::1", false},
+		{"nat64", "64:ff9b::a00:1", false},
+		{"teredo", "2001::1", false},
+		{"6to4", "2002:a00:1::1", false},
+		{"unique local", "fc00::1", false},
+		{"unique local fd", "fd12:3456::1", false},
+		{"ipv6 link-local", "fe80::1", false},
+		{"ipv6 link-local with zone", "fe80::1%eth0", false},
+		{"ipv6 multicast", "ff02::1", false},
+		{"configured deny", "93.184.216.34", false},
+		{"configured allow inside private range", "10.1.2.3", true},
+		{"private next to configured allow", "10.1.3.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.checkAddr(netip.MustParseAddr(tt.addr))
+			if tt.allowed && err != nil {
+				t.Fatalf("checkAddr(%s) = %v, want allowed", tt.addr, err)
+			}
+			if !tt.allowed && !errors.Is(err, domain.ErrSourceRejected) {
+				t.Fatalf("checkAddr(%s) = %v, want %v", tt.addr, err, domain.ErrSourceRejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package admission decides whether a freshly rendered variant is worth a
+// place in the cache, so one-off requests don't push out popular variants.
+package admission
+
+import (
+	"github.com/elect0/chimera/internal/config"
+)
+
+// Policy sees every cache miss through Record and is asked through Admit
+// whether the variant rendered for it should be stored.
+type Policy interface {
+	Name() string
+	Record(key string)
+	Admit(key string, size int) bool
+}
+
+// New builds the policies configured under cache.admission. A variant is
+// stored only if every returned policy admits it.
+func New(cfg *config.Config) []Policy {
+	admissionCfg := cfg.Cache.Admission
+
+	var policies []Policy
+	if admissionCfg.MaxSizeKB > 0 {
+		policies = append(policies, NewMaxSize(int64(admissionCfg.MaxSizeKB)*1024))
+	}
+
+	switch admissionCfg.Policy {
+	case "second_hit":
+		policies = append(policies, NewSecondHit(admissionCfg.SecondHit.MaxEntries))
+	case "tinylfu":
+		policies = append(policies, NewTinyLFU(admissionCfg.TinyLFU.Counters, admissionCfg.TinyLFU.MinFrequency))
+	}
+
+	return policies
+}
+
+// MaxSize rejects variants larger than a fixed number of bytes.
+type MaxSize struct {
+	maxBytes int64
+}
+
+func NewMaxSize(maxBytes int64) *MaxSize {
+	return &MaxSize{maxBytes: maxBytes}
+}
+
+func (p *MaxSize) Name() string { return "max_size" }
+
+func (p *MaxSize) Record(key string) {}
+
+func (p *MaxSize) Admit(key string, size int) bool {
+	return int64(size) <= p.maxBytes
+}
@@ -0,0 +1,36 @@
+package admission
+
+import (
+	"sync"
+
+	"github.com/elect0/chimera/internal/lru"
+)
+
+// SecondHit admits a variant once it missed the cache at least twice. Keys
+// are remembered in an lru, so a key that isn't requested again while
+// maxEntries other keys are seen starts over.
+type SecondHit struct {
+	// mu makes the read and write of a count one step, so concurrent misses
+	// of a key are all counted
+	mu   sync.Mutex
+	seen *lru.Cache[int]
+}
+
+func NewSecondHit(maxEntries int) *SecondHit {
+	return &SecondHit{seen: lru.New[int](int64(maxEntries))}
+}
+
+func (p *SecondHit) Name() string { return "second_hit" }
+
+func (p *SecondHit) Record(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count, _ := p.seen.Get(key)
+	p.seen.Set(key, count+1, 1, 0)
+}
+
+func (p *SecondHit) Admit(key string, size int) bool {
+	count, _ := p.seen.Get(key)
+	return count >= 2
+}
@@ -0,0 +1,40 @@
+package admission
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSecondHitConcurrentRecords(t *testing.T) {
+	p := NewSecondHit(1024)
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Record("key")
+		}()
+	}
+	wg.Wait()
+
+	if count, _ := p.seen.Get("key"); count != 100 {
+		t.Fatalf("recorded %d misses, want 100", count)
+	}
+	if !p.Admit("key", 0) {
+		t.Fatal("Admit() = false after concurrent misses, want true")
+	}
+}
+
+func TestSecondHitAdmitsOnSecondMiss(t *testing.T) {
+	p := NewSecondHit(1024)
+
+	p.Record("key")
+	if p.Admit("key", 0) {
+		t.Fatal("Admit() = true after one miss, want false")
+	}
+	p.Record("key")
+	if !p.Admit("key", 0) {
+		t.Fatal("Admit() = false after two misses, want true")
+	}
+}
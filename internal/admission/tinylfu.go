@@ -0,0 +1,104 @@
+package admission
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+const (
+	sketchDepth = 4
+	// counters saturate at 15, like the 4 bit counters of the TinyLFU paper
+	maxCount = 15
+)
+
+// TinyLFU estimates how often each key missed recently with a count-min
+// sketch and admits keys seen at least minFrequency times. All counters are
+// halved after every sampleSize increments, so old popularity fades out.
+type TinyLFU struct {
+	mu           sync.Mutex
+	seed         maphash.Seed
+	rows         [sketchDepth][]uint8
+	mask         uint64
+	additions    int
+	sampleSize   int
+	minFrequency int
+}
+
+func NewTinyLFU(counters, minFrequency int) *TinyLFU {
+	width := 1
+	for width < counters {
+		width <<= 1
+	}
+
+	p := &TinyLFU{
+		seed:         maphash.MakeSeed(),
+		mask:         uint64(width - 1),
+		sampleSize:   10 * width,
+		minFrequency: minFrequency,
+	}
+	for i := range p.rows {
+		p.rows[i] = make([]uint8, width)
+	}
+	return p
+}
+
+func (p *TinyLFU) Name() string { return "tinylfu" }
+
+func (p *TinyLFU) Record(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	indexes := p.indexes(key)
+	estimate := p.estimate(indexes)
+	if estimate >= maxCount {
+		return
+	}
+
+	// conservative update: only the counters holding the minimum grow, which
+	// keeps collisions from inflating the estimate of other keys
+	for row, i := range indexes {
+		if int(p.rows[row][i]) == estimate {
+			p.rows[row][i]++
+		}
+	}
+
+	p.additions++
+	if p.additions >= p.sampleSize {
+		p.reset()
+	}
+}
+
+func (p *TinyLFU) Admit(key string, size int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.estimate(p.indexes(key)) >= p.minFrequency
+}
+
+func (p *TinyLFU) indexes(key string) [sketchDepth]uint64 {
+	hash := maphash.String(p.seed, key)
+	h1, h2 := hash, hash>>32|1
+
+	var indexes [sketchDepth]uint64
+	for row := range indexes {
+		indexes[row] = (h1 + uint64(row)*h2) & p.mask
+	}
+	return indexes
+}
+
+func (p *TinyLFU) estimate(indexes [sketchDepth]uint64) int {
+	estimate := maxCount
+	for row, i := range indexes {
+		estimate = min(estimate, int(p.rows[row][i]))
+	}
+	return estimate
+}
+
+func (p *TinyLFU) reset() {
+	for row := range p.rows {
+		for i := range p.rows[row] {
+			p.rows[row][i] >>= 1
+		}
+	}
+	p.additions /= 2
+}
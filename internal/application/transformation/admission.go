@@ -0,0 +1,35 @@
+package transformation
+
+import (
+	"log/slog"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/metrics"
+)
+
+// admit records the miss with every admission policy and reports whether the
+// variant rendered for it should be stored. Rejected variants are still served,
+// they just have to be rendered again on the next request.
+func (s *Service) admit(cacheKey string, variant *domain.Variant, log *slog.Logger) bool {
+	for _, policy := range s.admission {
+		policy.Record(cacheKey)
+	}
+
+	for _, policy := range s.admission {
+		if !policy.Admit(cacheKey, len(variant.Data)) {
+			metrics.CacheAdmissionsTotal.WithLabelValues(policy.Name(), "rejected").Inc()
+			log.Debug("variant not admitted to the cache", slog.String("policy", policy.Name()))
+			return false
+		}
+	}
+
+	// the last policy to admit the variant decided, the configured one when it
+	// is checked at all
+	decidedBy := s.cfg.Cache.Admission.Policy
+	if len(s.admission) > 0 {
+		decidedBy = s.admission[len(s.admission)-1].Name()
+	}
+
+	metrics.CacheAdmissionsTotal.WithLabelValues(decidedBy, "admitted").Inc()
+	return true
+}
@@ -0,0 +1,41 @@
+package transformation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/imageinfo"
+	"github.com/h2non/bimg"
+)
+
+// checkSourceLimits rejects oversized sources before they reach the decoder,
+// reading nothing but the image headers.
+func (s *Service) checkSourceLimits(buf []byte) error {
+	if maxBytes := s.cfg.MaxSourceBytes(); int64(len(buf)) > maxBytes {
+		return &domain.LimitError{Err: domain.ErrSourceTooLarge, Limit: maxBytes, Actual: int64(len(buf))}
+	}
+
+	info, err := imageinfo.Inspect(buf)
+	if errors.Is(err, imageinfo.ErrUnknownFormat) {
+		// formats such as AVIF or HEIF are left to libvips, which loads images
+		// lazily and only parses the header to report the size
+		size, sizeErr := bimg.Size(buf)
+		if sizeErr != nil {
+			return fmt.Errorf("%w: %w", domain.ErrSourceUnsupported, sizeErr)
+		}
+		info = imageinfo.Info{Width: size.Width, Height: size.Height, Frames: 1}
+	} else if err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrSourceUnsupported, err)
+	}
+
+	if maxPixels := s.cfg.Limits.MaxSourcePixels; info.Pixels() > maxPixels {
+		return &domain.LimitError{Err: domain.ErrSourceTooManyPixels, Limit: maxPixels, Actual: info.Pixels()}
+	}
+
+	if maxFrames := s.cfg.Limits.MaxFrames; info.Frames > maxFrames {
+		return &domain.LimitError{Err: domain.ErrSourceTooManyFrames, Limit: int64(maxFrames), Actual: int64(info.Frames)}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package transformation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/metrics"
+)
+
+// fetchSource loads and checks the source image of a render. Sources that are
+// missing, unsupported or rejected by the SSRF checks are remembered for a
+// short while, so repeated requests for them don't reach the origin again.
+func (s *Service) fetchSource(ctx context.Context, src sourceRef) (*domain.SourceImage, error) {
+	if err, ok := s.negative.Get(src.id()); ok {
+		metrics.NegativeCacheHitsTotal.WithLabelValues(negativeReason(err)).Inc()
+		return nil, err
+	}
+
+	source, err := src.repo.Get(ctx, src.key)
+	if err == nil {
+		err = s.checkSourceLimits(source.Data)
+	}
+	if err != nil {
+		if ttl := s.negativeTTL(err); ttl > 0 {
+			s.negative.Set(src.id(), err, 1, ttl)
+			metrics.NegativeCacheStoredTotal.WithLabelValues(negativeReason(err)).Inc()
+		}
+		return nil, err
+	}
+
+	return source, nil
+}
+
+func (s *Service) negativeTTL(err error) time.Duration {
+	negative := s.cfg.Cache.Negative
+	if !negative.Enabled {
+		return 0
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrSourceNotFound):
+		return negative.NotFoundTTL
+	case errors.Is(err, domain.ErrSourceUnsupported):
+		return negative.UnsupportedTTL
+	case errors.Is(err, domain.ErrSourceRejected):
+		return negative.RejectedTTL
+	}
+	return 0
+}
+
+func negativeReason(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrSourceNotFound):
+		return "not_found"
+	case errors.Is(err, domain.ErrSourceUnsupported):
+		return "unsupported"
+	case errors.Is(err, domain.ErrSourceRejected):
+		return "rejected"
+	}
+	return "other"
+}
@@ -0,0 +1,62 @@
+package transformation
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/metrics"
+)
+
+// revalidate checks a cached variant against the current version of its
+// source, at most once per interval and source version, and discards every
+// variant of the source once it changed. In synchronous mode the check runs
+// before the hit is served and reports whether the variant must be dropped;
+// otherwise it runs in the background and the hit is served as is.
+func (s *Service) revalidate(ctx context.Context, src sourceRef, cacheKey string, variant *domain.Variant, log *slog.Logger) bool {
+	revalidation := s.cfg.Cache.Revalidation
+	if !revalidation.Enabled || variant.SourceVersion == "" {
+		return false
+	}
+
+	confirmedKey := src.id() + "@" + variant.SourceVersion
+	if _, ok := s.confirmed.Get(confirmedKey); ok {
+		return false
+	}
+
+	check := func(ctx context.Context) bool {
+		current, err := src.repo.Validate(ctx, src.key, variant.SourceVersion)
+		if err != nil {
+			// an unreachable origin is not a reason to drop a good variant
+			metrics.SourceRevalidationsTotal.WithLabelValues("error").Inc()
+			log.Warn("failed to revalidate source", slog.String("error", err.Error()))
+			return false
+		}
+
+		if current {
+			metrics.SourceRevalidationsTotal.WithLabelValues("unchanged").Inc()
+			s.confirmed.Set(confirmedKey, struct{}{}, 1, revalidation.Interval)
+			return false
+		}
+
+		metrics.SourceRevalidationsTotal.WithLabelValues("changed").Inc()
+		log.Info("source changed, discarding its variants", slog.String("version", variant.SourceVersion))
+
+		if _, err := s.cacheRepo.Delete(ctx, cacheKey); err != nil {
+			log.Error("failed to delete outdated variant", slog.String("error", err.Error()))
+		}
+		if _, err := s.Purge(ctx, domain.PurgeSelector{Path: src.path}); err != nil {
+			log.Error("failed to purge outdated variants", slog.String("error", err.Error()))
+		}
+		return true
+	}
+
+	if revalidation.Synchronous {
+		return check(ctx)
+	}
+
+	s.group.DoChan("revalidate:"+confirmedKey, func() (any, error) {
+		return check(context.Background()), nil
+	})
+	return false
+}
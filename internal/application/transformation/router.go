@@ -0,0 +1,181 @@
+package transformation
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+// sourceRef is a requested source image resolved to the origin serving it.
+type sourceRef struct {
+	// path is the source as requested, it identifies the source for purging
+	path   string
+	origin string
+	repo   ports.OriginRepository
+	// key is the location handed to the origin
+	key string
+}
+
+// id identifies the source across origins.
+func (r sourceRef) id() string {
+	return r.origin + ":" + r.key
+}
+
+type route struct {
+	cfg  config.Origin
+	repo ports.OriginRepository
+}
+
+type router struct {
+	routes []route
+	byName map[string]route
+	remote config.RemoteFetch
+}
+
+func newRouter(cfg *config.Config, origins map[string]ports.OriginRepository) *router {
+	r := &router{byName: make(map[string]route, len(cfg.Origins)), remote: cfg.Security.RemoteFetch}
+	for _, origin := range cfg.Origins {
+		rt := route{cfg: origin, repo: origins[origin.Name]}
+		r.routes = append(r.routes, rt)
+		r.byName[origin.Name] = rt
+	}
+	return r
+}
+
+// resolve picks the origin named by the request, or else the http origin
+// accepting the host of a url, or else the origin with the longest matching
+// path prefix. Urls from hosts that aren't allowed are rejected whichever
+// origin they'd go to.
+func (r *router) resolve(imagePath, originName string) (sourceRef, error) {
+	if isURL(imagePath) {
+		parsed, err := url.Parse(imagePath)
+		if err != nil {
+			return sourceRef{}, fmt.Errorf("%w: invalid url: %w", domain.ErrSourceRejected, err)
+		}
+		if !r.remote.HostAllowed(parsed.Hostname()) {
+			return sourceRef{}, &domain.HostError{Host: parsed.Hostname()}
+		}
+	}
+
+	if originName != "" {
+		rt, ok := r.byName[originName]
+		if !ok {
+			return sourceRef{}, fmt.Errorf("%w: '%s'", domain.ErrUnknownOrigin, originName)
+		}
+		return rt.ref(imagePath)
+	}
+
+	if isURL(imagePath) {
+		return r.resolveURL(imagePath)
+	}
+
+	var (
+		best  route
+		found bool
+	)
+	for _, rt := range r.routes {
+		if rt.cfg.Type == "http" && rt.cfg.HTTP.BaseURL == "" {
+			// only fetches absolute urls
+			continue
+		}
+		if !strings.HasPrefix(imagePath, rt.cfg.PathPrefix) {
+			continue
+		}
+		if !found || len(rt.cfg.PathPrefix) > len(best.cfg.PathPrefix) {
+			best, found = rt, true
+		}
+	}
+	if !found {
+		return sourceRef{}, fmt.Errorf("%w: no origin serves '%s'", domain.ErrUnknownOrigin, imagePath)
+	}
+	return best.ref(imagePath)
+}
+
+func (r *router) resolveURL(imageURL string) (sourceRef, error) {
+	parsed, err := url.Parse(imageURL)
+	if err != nil {
+		return sourceRef{}, fmt.Errorf("%w: invalid url: %w", domain.ErrSourceRejected, err)
+	}
+	host := strings.ToLower(parsed.Hostname())
+
+	var fallback *route
+	for _, rt := range r.routes {
+		if rt.cfg.Type != "http" {
+			continue
+		}
+		if len(rt.cfg.Hosts) == 0 {
+			if rt.cfg.HTTP.BaseURL == "" && fallback == nil {
+				fallback = &rt
+			}
+			continue
+		}
+		for _, pattern := range rt.cfg.Hosts {
+			if config.HostMatches(host, pattern) {
+				return rt.ref(imageURL)
+			}
+		}
+	}
+
+	if fallback == nil {
+		return sourceRef{}, fmt.Errorf("%w: no origin accepts urls from '%s'", domain.ErrSourceRejected, host)
+	}
+	return fallback.ref(imageURL)
+}
+
+func (rt route) ref(imagePath string) (sourceRef, error) {
+	ref := sourceRef{path: imagePath, origin: rt.cfg.Name, repo: rt.repo, key: imagePath}
+
+	if rt.cfg.Type == "http" && isURL(imagePath) {
+		return ref, rt.acceptURL(imagePath)
+	}
+
+	if rt.cfg.StripPrefix {
+		ref.key = strings.TrimPrefix(ref.key, rt.cfg.PathPrefix)
+	}
+
+	if rt.cfg.Type == "http" {
+		if rt.cfg.HTTP.BaseURL == "" {
+			return sourceRef{}, fmt.Errorf("%w: origin '%s' only fetches absolute urls", domain.ErrUnknownOrigin, rt.cfg.Name)
+		}
+		// cleaning the path first keeps ".." from climbing above the base url
+		location, err := url.JoinPath(rt.cfg.HTTP.BaseURL, path.Clean("/"+ref.key))
+		if err != nil {
+			return sourceRef{}, fmt.Errorf("%w: %w", domain.ErrSourceRejected, err)
+		}
+		ref.key = location
+	}
+
+	return ref, nil
+}
+
+// acceptURL keeps absolute urls named explicitly through origin= within what
+// the origin would have been picked for, so its headers never go elsewhere.
+func (rt route) acceptURL(imageURL string) error {
+	if rt.cfg.HTTP.BaseURL != "" {
+		return fmt.Errorf("%w: origin '%s' only fetches paths below its base url", domain.ErrSourceRejected, rt.cfg.Name)
+	}
+	if len(rt.cfg.Hosts) == 0 {
+		return nil
+	}
+
+	parsed, err := url.Parse(imageURL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid url: %w", domain.ErrSourceRejected, err)
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, pattern := range rt.cfg.Hosts {
+		if config.HostMatches(host, pattern) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: origin '%s' doesn't accept urls from '%s'", domain.ErrSourceRejected, rt.cfg.Name, host)
+}
+
+func isURL(imagePath string) bool {
+	return strings.HasPrefix(imagePath, "http://") || strings.HasPrefix(imagePath, "https://")
+}
@@ -0,0 +1,65 @@
+package transformation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+)
+
+func newTestRouter() *router {
+	cfg := &config.Config{}
+
+	images := config.Origin{Name: "images", Type: "http", PathPrefix: "/img/"}
+	images.HTTP.BaseURL = "https://images.internal.example/base"
+	images.HTTP.Headers = map[string]string{"authorization": "Bearer token"}
+
+	cdn := config.Origin{Name: "cdn", Type: "http", Hosts: []string{"*.cdn.example"}}
+	web := config.Origin{Name: "web", Type: "http"}
+
+	cfg.Origins = []config.Origin{images, cdn, web}
+	return newRouter(cfg, nil)
+}
+
+func TestRouterResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		origin     string
+		wantOrigin string
+		wantKey    string
+		wantErr    error
+	}{
+		{"path below base url", "/img/a.jpg", "", "images", "https://images.internal.example/base/img/a.jpg", nil},
+		{"path climbing above base url", "/img/../../etc/passwd", "", "images", "https://images.internal.example/base/etc/passwd", nil},
+		{"url routed by host", "https://a.cdn.example/x.jpg", "", "cdn", "https://a.cdn.example/x.jpg", nil},
+		{"url routed to the fallback", "https://other.example/x.jpg", "", "web", "https://other.example/x.jpg", nil},
+		{"named origin with its base url", "/img/a.jpg", "images", "images", "https://images.internal.example/base/img/a.jpg", nil},
+		{"named base url origin with a foreign url", "https://attacker.example/x", "images", "", "", domain.ErrSourceRejected},
+		{"named base url origin with its own host", "https://images.internal.example:8080/x", "images", "", "", domain.ErrSourceRejected},
+		{"named origin with a matching host", "https://A.CDN.example/x.jpg", "cdn", "cdn", "https://A.CDN.example/x.jpg", nil},
+		{"named origin with a foreign host", "https://attacker.example/x", "cdn", "", "", domain.ErrSourceRejected},
+		{"named origin without hosts", "https://other.example/x.jpg", "web", "web", "https://other.example/x.jpg", nil},
+		{"unknown origin", "/a.jpg", "missing", "", "", domain.ErrUnknownOrigin},
+	}
+
+	r := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := r.resolve(tt.path, tt.origin)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("resolve(%q, %q) = %+v, %v, want %v", tt.path, tt.origin, ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve(%q, %q) error = %v", tt.path, tt.origin, err)
+			}
+			if ref.origin != tt.wantOrigin || ref.key != tt.wantKey {
+				t.Fatalf("resolve(%q, %q) = %s %s, want %s %s", tt.path, tt.origin, ref.origin, ref.key, tt.wantOrigin, tt.wantKey)
+			}
+		})
+	}
+}
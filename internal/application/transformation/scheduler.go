@@ -0,0 +1,104 @@
+package transformation
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/metrics"
+	"github.com/h2non/bimg"
+)
+
+// lane bounds how many transformations run at once and how many may wait for
+// a slot. Anything beyond that is rejected right away instead of piling up.
+type lane struct {
+	name     string
+	slots    chan struct{}
+	waiting  atomic.Int64
+	maxQueue int64
+}
+
+func newLane(name string, workers, queueSize int) *lane {
+	return &lane{
+		name:     name,
+		slots:    make(chan struct{}, workers),
+		maxQueue: int64(queueSize),
+	}
+}
+
+type scheduler struct {
+	cheap            *lane
+	expensive        *lane
+	expensiveFormats []string
+	queueTimeout     time.Duration
+}
+
+func newScheduler(cfg *config.Config) *scheduler {
+	return &scheduler{
+		cheap:            newLane("cheap", cfg.Processing.Cheap.Workers, cfg.Processing.Cheap.QueueSize),
+		expensive:        newLane("expensive", cfg.Processing.Expensive.Workers, cfg.Processing.Expensive.QueueSize),
+		expensiveFormats: cfg.Processing.ExpensiveFormats,
+		queueTimeout:     cfg.Processing.QueueTimeout,
+	}
+}
+
+func (s *scheduler) Run(ctx context.Context, targetType bimg.ImageType, fn func() error) error {
+	l := s.cheap
+	if slices.Contains(s.expensiveFormats, bimg.ImageTypeName(targetType)) {
+		l = s.expensive
+	}
+
+	queuedAt := time.Now()
+	if err := l.acquire(ctx, s.queueTimeout); err != nil {
+		return err
+	}
+	defer l.release()
+
+	metrics.ProcessingQueueDuration.WithLabelValues(l.name).Observe(time.Since(queuedAt).Seconds())
+
+	start := time.Now()
+	err := fn()
+	metrics.ProcessingDuration.WithLabelValues(l.name).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+func (l *lane) acquire(ctx context.Context, timeout time.Duration) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.waiting.Add(1) > l.maxQueue {
+		l.waiting.Add(-1)
+		metrics.ProcessingRejectedTotal.WithLabelValues(l.name).Inc()
+		return domain.ErrOverloaded
+	}
+	metrics.ProcessingQueueDepth.WithLabelValues(l.name).Inc()
+
+	defer func() {
+		l.waiting.Add(-1)
+		metrics.ProcessingQueueDepth.WithLabelValues(l.name).Dec()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		metrics.ProcessingRejectedTotal.WithLabelValues(l.name).Inc()
+		return domain.ErrOverloaded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *lane) release() {
+	<-l.slots
+}
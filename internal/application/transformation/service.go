@@ -2,63 +2,322 @@ package transformation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/elect0/chimera/internal/admission"
+	"github.com/elect0/chimera/internal/config"
 	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/lru"
 	"github.com/elect0/chimera/internal/metrics"
 	"github.com/elect0/chimera/internal/ports"
 	"github.com/h2non/bimg"
-	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 type Service struct {
-	log            *slog.Logger
-	s3OriginRepo   ports.OriginRepository
-	cacheRepo      ports.CacheRepository
-	httpOriginRepo ports.OriginRepository
+	log       *slog.Logger
+	cfg       *config.Config
+	router    *router
+	cacheRepo ports.CacheRepository
+	locker    ports.Locker
+	index     ports.VariantIndex
+	group     singleflight.Group
+	scheduler *scheduler
+	confirmed *lru.Cache[struct{}]
+	negative  *lru.Cache[error]
+	admission []admission.Policy
+	writer    *cacheWriter
 }
 
-func NewService(log *slog.Logger, originRepo ports.OriginRepository, cacheRepo ports.CacheRepository, httpRepo ports.OriginRepository) *Service {
+// NewService builds the service on top of the configured origins, keyed by
+// their name.
+func NewService(log *slog.Logger, cfg *config.Config, origins map[string]ports.OriginRepository, cacheRepo ports.CacheRepository, locker ports.Locker, index ports.VariantIndex) *Service {
 	return &Service{
-		log:            log,
-		s3OriginRepo:   originRepo,
-		httpOriginRepo: httpRepo,
-		cacheRepo:      cacheRepo,
+		log:       log,
+		cfg:       cfg,
+		router:    newRouter(cfg, origins),
+		cacheRepo: cacheRepo,
+		locker:    locker,
+		index:     index,
+		scheduler: newScheduler(cfg),
+		confirmed: lru.New[struct{}](int64(cfg.Cache.Revalidation.MaxConfirmed)),
+		negative:  lru.New[error](int64(cfg.Cache.Negative.MaxEntries)),
+		admission: admission.New(cfg),
+		writer:    newCacheWriter(cfg, cacheRepo, index),
 	}
 }
 
-func (s *Service) Process(ctx context.Context, opts domain.TransformationOptions, imagePath string) ([]byte, error) {
+func (s *Service) Process(ctx context.Context, opts domain.TransformationOptions, imagePath string) (*domain.Variant, error) {
+	src, err := s.router.resolve(imagePath, opts.Origin)
+	if err != nil {
+		return nil, err
+	}
 
-	cacheKey := fmt.Sprintf("%s:w%d:h%d:q%d", imagePath, opts.Width, opts.Height, opts.Quality)
-	log := s.log.With(slog.String("cacheKey", cacheKey))
+	cacheKey := variantKey(imagePath, opts)
+	log := s.log.With(slog.String("cacheKey", cacheKey), slog.String("origin", src.origin))
 
-	cachedImage, err := s.cacheRepo.Get(ctx, cacheKey)
-	if err == nil {
-		log.Info("cache hit")
-		metrics.CacheHitTotals.Inc()
-		return cachedImage, nil
-	}
+	ttl := s.ttlFor(src)
 
-	if err != redis.Nil {
+	cachedVariant, err := s.cacheRepo.Get(ctx, cacheKey)
+	if err != nil && !errors.Is(err, domain.ErrCacheMiss) {
 		log.Error("error getting from cache", slog.String("error", err.Error()))
 	}
+
+	if cachedVariant != nil && s.revalidate(ctx, src, cacheKey, cachedVariant, log) {
+		cachedVariant = nil
+	}
+
+	if cachedVariant != nil {
+		age := cachedVariant.Age()
+		if age <= ttl {
+			log.Info("cache hit")
+			metrics.CacheHitTotals.Inc()
+			s.indexTags(opts, src, cacheKey, cachedVariant, log)
+			return cachedVariant, nil
+		}
+
+		if age <= ttl+s.cfg.Cache.StaleWhileRevalidate {
+			log.Info("serving stale variant while revalidating", slog.Duration("age", age))
+			metrics.CacheStaleServedTotal.WithLabelValues("revalidate").Inc()
+			s.indexTags(opts, src, cacheKey, cachedVariant, log)
+			s.refresh(opts, src, cacheKey, log)
+			return cachedVariant, nil
+		}
+	}
 	log.Info("cache miss")
 	metrics.CacheMissesTotal.Inc()
 
-	var originalImage []byte
-	if strings.HasPrefix(imagePath, "http") {
-		originalImage, err = s.httpOriginRepo.Get(ctx, imagePath)
-	} else {
-		originalImage, err = s.s3OriginRepo.Get(ctx, imagePath)
+	variant, err := s.renderShared(ctx, opts, src, cacheKey, log)
+	if err != nil {
+		if cachedVariant != nil && ctx.Err() == nil && cachedVariant.Age() <= ttl+s.cfg.Cache.StaleIfError {
+			log.Warn("serving stale variant after failed render", slog.String("error", err.Error()))
+			metrics.CacheStaleServedTotal.WithLabelValues("error").Inc()
+			return cachedVariant, nil
+		}
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+func (s *Service) renderShared(ctx context.Context, opts domain.TransformationOptions, src sourceRef, cacheKey string, log *slog.Logger) (*domain.Variant, error) {
+	// concurrent misses for the same variant share a single fetch and encode; the
+	// shared work is detached from the leader's context so its cancellation doesn't
+	// fail every other waiter.
+	// res.Shared is also set for the leader, so followers are told apart by
+	// whether their function ran
+	var leader bool
+	result := s.group.DoChan(cacheKey, func() (any, error) {
+		leader = true
+		return s.renderOnce(context.WithoutCancel(ctx), opts, src, cacheKey, log)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if !leader {
+			metrics.CoalescedRequestsTotal.Inc()
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*domain.Variant), nil
+	}
+}
+
+// refresh re-renders a variant in the background, joining a render of the same
+// variant that is already in flight.
+func (s *Service) refresh(opts domain.TransformationOptions, src sourceRef, cacheKey string, log *slog.Logger) {
+	s.group.DoChan(cacheKey, func() (any, error) {
+		return s.renderOnce(context.Background(), opts, src, cacheKey, log)
+	})
+}
+
+func (s *Service) renderOnce(ctx context.Context, opts domain.TransformationOptions, src sourceRef, cacheKey string, log *slog.Logger) (*domain.Variant, error) {
+	unlock := func() {}
+
+	if s.locker != nil {
+		release, acquired, err := s.locker.Acquire(ctx, cacheKey, s.cfg.Coalescing.DistributedLock.TTL)
+		switch {
+		case err != nil:
+			metrics.DistributedLockTotal.WithLabelValues("error").Inc()
+			log.Warn("failed to acquire distributed lock, rendering without it", slog.String("error", err.Error()))
+		case acquired:
+			metrics.DistributedLockTotal.WithLabelValues("acquired").Inc()
+			unlock = release
+		default:
+			metrics.DistributedLockTotal.WithLabelValues("contended").Inc()
+			log.Debug("variant is being rendered by another replica, waiting for it")
+			if variant, ok := s.waitForCache(ctx, src, cacheKey, log); ok {
+				return variant, nil
+			}
+			log.Warn("timed out waiting for another replica, rendering locally")
+		}
+	}
+
+	variant, err := s.render(ctx, opts, src, log)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	variant.ExpiresAt = variant.CreatedAt.Add(s.lifetime(src))
+
+	if !s.admit(cacheKey, variant, log) {
+		unlock()
+		return variant, nil
+	}
+
+	s.writer.Write(cacheWrite{
+		cacheKey:  cacheKey,
+		imagePath: src.path,
+		tags:      opts.Tags,
+		variant:   variant,
+		done:      unlock,
+		log:       log,
+	})
+
+	return variant, nil
+}
+
+// Close waits for pending cache writes to finish, or for ctx to be done.
+func (s *Service) Close(ctx context.Context) error {
+	return s.writer.Close(ctx)
+}
+
+// Purge deletes every indexed variant matching the selector from all cache
+// tiers and reports how many were removed.
+func (s *Service) Purge(ctx context.Context, selector domain.PurgeSelector) (int, error) {
+	keys, err := s.index.Lookup(ctx, selector)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up variants: %w", err)
+	}
+
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	// the cached source goes first, so variants rendered while purging can't
+	// come from the old copy
+	var (
+		src      sourceRef
+		resolved bool
+	)
+	if selector.Path != "" {
+		var resolveErr error
+		src, resolveErr = s.router.resolve(selector.Path, "")
+		resolved = resolveErr == nil
+	}
+	if invalidator, ok := src.repo.(ports.SourceInvalidator); resolved && ok {
+		if err := invalidator.Invalidate(ctx, src.key); err != nil {
+			return 0, fmt.Errorf("failed to invalidate cached source: %w", err)
+		}
+	}
+
+	removed, err := s.cacheRepo.Delete(ctx, keys...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete variants: %w", err)
+	}
+
+	if err := s.index.Remove(ctx, selector); err != nil {
+		return 0, fmt.Errorf("failed to remove variant index: %w", err)
+	}
+
+	if resolved {
+		s.negative.Delete(src.id())
+	}
+
+	metrics.PurgedVariantsTotal.Add(float64(removed))
+	s.log.Info("purged variants", slog.String("path", selector.Path), slog.String("prefix", selector.Prefix), slog.String("tag", selector.Tag), slog.Int("indexed", len(keys)), slog.Int("removed", removed))
+
+	return removed, nil
+}
+
+// waitForCache polls for the variant another replica is rendering. Entries
+// that a lookup wouldn't serve as fresh, like the expired one that caused the
+// miss, are passed over until the new one shows up.
+func (s *Service) waitForCache(ctx context.Context, src sourceRef, cacheKey string, log *slog.Logger) (*domain.Variant, bool) {
+	lockCfg := s.cfg.Coalescing.DistributedLock
+	ttl := s.ttlFor(src)
+
+	ctx, cancel := context.WithTimeout(ctx, lockCfg.WaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(lockCfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-ticker.C:
+			variant, err := s.cacheRepo.Get(ctx, cacheKey)
+			if err != nil || variant.Age() > ttl {
+				continue
+			}
+			if s.revalidate(ctx, src, cacheKey, variant, log) {
+				continue
+			}
+			return variant, true
+		}
+	}
+}
+
+func (s *Service) render(ctx context.Context, opts domain.TransformationOptions, src sourceRef, log *slog.Logger) (*domain.Variant, error) {
+	source, err := s.fetchSource(ctx, src)
+	if err != nil {
+		log.Warn("failed to load source image", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	var watermarkBuffer []byte
+	if opts.Watermark.Path != "" {
+		s.log.Debug("watermark requested, fetching watermark image", slog.String("path", opts.Watermark.Path))
+
+		watermarkSrc, err := s.router.resolve(opts.Watermark.Path, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to route watermark image: %w", err)
+		}
+
+		watermark, err := watermarkSrc.repo.Get(ctx, watermarkSrc.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch watermark image: %w", err)
+		}
+		watermarkBuffer = watermark.Data
+
+		if err := s.checkSourceLimits(watermarkBuffer); err != nil {
+			return nil, fmt.Errorf("watermark image rejected: %w", err)
+		}
 	}
 
+	var variant *domain.Variant
+	err = s.scheduler.Run(ctx, opts.TargetType, func() error {
+		newImage, err := s.transform(source.Data, watermarkBuffer, opts)
+		if err != nil {
+			return err
+		}
+
+		size, err := bimg.Size(newImage)
+		if err != nil {
+			return err
+		}
+
+		variant = domain.NewVariant(newImage, opts.TargetType, size.Width, size.Height, source.Version)
+		return nil
+	})
 	if err != nil {
-		log.Error("failed to get original image from origin", slog.String("error", err.Error()))
 		return nil, err
 	}
 
+	return variant, nil
+}
+
+func (s *Service) transform(originalImage, watermarkBuffer []byte, opts domain.TransformationOptions) ([]byte, error) {
 	image := bimg.NewImage(originalImage)
 	bimgOptions := bimg.Options{
 		Width:   opts.Width,
@@ -78,14 +337,7 @@ func (s *Service) Process(ctx context.Context, opts domain.TransformationOptions
 		return nil, err
 	}
 
-	if opts.Watermark.Path != "" {
-		s.log.Debug("watermark requested, fetching watermark image", slog.String("path", opts.Watermark.Path))
-
-		watermarkBuffer, err := s.s3OriginRepo.Get(ctx, opts.Watermark.Path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch watermark image: %w", err)
-		}
-
+	if watermarkBuffer != nil {
 		processedSize, err := bimg.Size(newImage)
 		if err != nil {
 			return nil, err
@@ -113,17 +365,67 @@ func (s *Service) Process(ctx context.Context, opts domain.TransformationOptions
 		}
 	}
 
-	go func() {
-		err := s.cacheRepo.Set(context.Background(), cacheKey, newImage)
-		if err != nil {
-			log.Error("failed to set item in cache", slog.String("error", err.Error()))
-		}
-		log.Info("successfully set item in cache")
-	}()
-
 	return newImage, nil
 }
 
+// indexTags adds the tags of a request served from the cache to the purge
+// index. Tags aren't part of the variant key, so a variant shared by requests
+// tagged differently has to be found by every one of their tags.
+func (s *Service) indexTags(opts domain.TransformationOptions, src sourceRef, cacheKey string, variant *domain.Variant, log *slog.Logger) {
+	if len(opts.Tags) == 0 {
+		return
+	}
+
+	// expiries aren't kept by every tier
+	indexed := *variant
+	indexed.ExpiresAt = variant.CreatedAt.Add(s.lifetime(src))
+
+	s.writer.Write(cacheWrite{
+		cacheKey:  cacheKey,
+		imagePath: src.path,
+		tags:      opts.Tags,
+		variant:   &indexed,
+		indexOnly: true,
+		done:      func() {},
+		log:       log,
+	})
+}
+
+// lifetime is how long a variant is kept, as long as any stale window may
+// still serve it.
+func (s *Service) lifetime(src sourceRef) time.Duration {
+	return s.ttlFor(src) + max(s.cfg.Cache.StaleWhileRevalidate, s.cfg.Cache.StaleIfError)
+}
+
+func (s *Service) ttlFor(src sourceRef) time.Duration {
+	// viper lowercases the keys of origin_ttl
+	if ttl, ok := s.cfg.Cache.OriginTTL[strings.ToLower(src.origin)]; ok {
+		return ttl
+	}
+	return s.cfg.Cache.TTL
+}
+
+// variantKey identifies a rendered variant. The output format is part of the
+// key since the negotiated type depends on the client's Accept header.
+func variantKey(imagePath string, opts domain.TransformationOptions) string {
+	key := fmt.Sprintf("%s:w%d:h%d:q%d:%s", imagePath, opts.Width, opts.Height, opts.Quality, bimg.ImageTypeName(opts.TargetType))
+
+	// without an explicit origin the path alone decides where the source comes from
+	if opts.Origin != "" {
+		key += ":o" + opts.Origin
+	}
+
+	if opts.Crop != "" {
+		key += ":c" + opts.Crop
+	}
+
+	if opts.Watermark.Path != "" {
+		key += fmt.Sprintf(":wm%s,%d,%.2f", opts.Watermark.Path, opts.Watermark.Position, opts.Watermark.Opacity)
+	}
+
+	return key
+}
+
 func calculateCoordinates(baseSize, watermarkSize bimg.ImageSize, gravity bimg.Gravity) (top, left int) {
 	switch gravity {
 	case bimg.GravityNorth:
@@ -0,0 +1,69 @@
+package transformation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/elect0/chimera/internal/adapters/cache"
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/h2non/bimg"
+)
+
+func TestPurgeByTagAcrossTaggings(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Origins = []config.Origin{{Name: "local", Type: "local"}}
+	cfg.Cache.TTL = time.Hour
+	cfg.Cache.Writer.Synchronous = true
+	cfg.Cache.Writer.Timeout = time.Second
+	cfg.Cache.Revalidation.MaxConfirmed = 16
+	cfg.Cache.Negative.MaxEntries = 16
+	cfg.Processing.Cheap.Workers = 1
+	cfg.Processing.Expensive.Workers = 1
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := cache.NewMemoryCacheRepositoryWithCapacity(1<<20, 0, log)
+	index := cache.NewMemoryVariantIndex()
+	s := NewService(log, cfg, nil, store, nil, index)
+	ctx := context.Background()
+
+	imagePath := "products/1.jpg"
+	opts := domain.TransformationOptions{Width: 100, TargetType: bimg.JPEG, Tags: []string{"summer"}}
+	cacheKey := variantKey(imagePath, opts)
+
+	// the first request renders and stores the variant with its tags
+	variant := domain.NewVariant([]byte("jpeg"), bimg.JPEG, 100, 0, "v1")
+	variant.ExpiresAt = variant.CreatedAt.Add(time.Hour)
+	s.writer.Write(cacheWrite{cacheKey: cacheKey, imagePath: imagePath, tags: opts.Tags, variant: variant, done: func() {}, log: log})
+
+	// the same variant requested with other tags is served from the cache
+	opts.Tags = []string{"sale"}
+	if _, err := s.Process(ctx, opts, imagePath); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	for _, tag := range []string{"sale", "summer"} {
+		keys, err := index.Lookup(ctx, domain.PurgeSelector{Tag: tag})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 1 || keys[0] != cacheKey {
+			t.Fatalf("index for tag %q = %v, want [%s]", tag, keys, cacheKey)
+		}
+	}
+
+	removed, err := s.Purge(ctx, domain.PurgeSelector{Tag: "sale"})
+	if err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Purge() removed %d variants, want 1", removed)
+	}
+	if _, err := store.Get(ctx, cacheKey); !errors.Is(err, domain.ErrCacheMiss) {
+		t.Fatalf("Get() after purge = %v, want %v", err, domain.ErrCacheMiss)
+	}
+}
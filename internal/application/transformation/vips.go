@@ -0,0 +1,23 @@
+package transformation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/h2non/bimg"
+)
+
+// CheckVips reports whether libvips can load and save the formats every
+// request can fall back to. bimg initializes libvips once when it is loaded,
+// initializing it again here would reset its settings under running renders.
+func CheckVips(ctx context.Context) error {
+	for _, imageType := range []bimg.ImageType{bimg.JPEG, bimg.PNG} {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !bimg.VipsIsTypeSupported(imageType) || !bimg.VipsIsTypeSupportedSave(imageType) {
+			return fmt.Errorf("libvips %s does not support %s", bimg.VipsVersion, bimg.ImageTypeName(imageType))
+		}
+	}
+	return nil
+}
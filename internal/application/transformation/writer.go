@@ -0,0 +1,171 @@
+package transformation
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/elect0/chimera/internal/config"
+	"github.com/elect0/chimera/internal/domain"
+	"github.com/elect0/chimera/internal/metrics"
+	"github.com/elect0/chimera/internal/ports"
+)
+
+type cacheWrite struct {
+	cacheKey  string
+	imagePath string
+	tags      []string
+	variant   *domain.Variant
+	// indexOnly records the tags of a variant served from the cache without
+	// storing it again
+	indexOnly bool
+	// done runs once the write finished or was dropped
+	done func()
+	log  *slog.Logger
+}
+
+// cacheWriter stores rendered variants off the request path with a fixed
+// number of workers. When the queue is full, writes are dropped rather than
+// piling up goroutines that each hold a full image buffer.
+type cacheWriter struct {
+	cacheRepo   ports.CacheRepository
+	index       ports.VariantIndex
+	timeout     time.Duration
+	synchronous bool
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan cacheWrite
+	wg     sync.WaitGroup
+}
+
+func newCacheWriter(cfg *config.Config, cacheRepo ports.CacheRepository, index ports.VariantIndex) *cacheWriter {
+	writerCfg := cfg.Cache.Writer
+
+	w := &cacheWriter{
+		cacheRepo:   cacheRepo,
+		index:       index,
+		timeout:     writerCfg.Timeout,
+		synchronous: writerCfg.Synchronous,
+		queue:       make(chan cacheWrite, writerCfg.QueueSize),
+	}
+
+	if !w.synchronous {
+		for range writerCfg.Workers {
+			w.wg.Add(1)
+			go w.work()
+		}
+	}
+
+	return w
+}
+
+// Write stores the variant, in the background unless the writer is
+// synchronous.
+func (w *cacheWriter) Write(job cacheWrite) {
+	if w.synchronous {
+		w.write(job)
+		return
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.drop(job, "closed")
+		return
+	}
+
+	select {
+	case w.queue <- job:
+		metrics.CacheWriteQueueDepth.Set(float64(len(w.queue)))
+	default:
+		w.drop(job, "dropped")
+	}
+}
+
+// Close stops accepting writes and waits for the queued ones to finish, or for
+// ctx to be done.
+func (w *cacheWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *cacheWriter) work() {
+	defer w.wg.Done()
+
+	for job := range w.queue {
+		metrics.CacheWriteQueueDepth.Set(float64(len(w.queue)))
+		w.write(job)
+	}
+}
+
+func (w *cacheWriter) write(job cacheWrite) {
+	defer job.done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	if job.indexOnly {
+		w.addToIndex(ctx, job)
+		return
+	}
+
+	err := w.cacheRepo.Set(ctx, job.cacheKey, job.variant)
+
+	// a variant stored by any tier is indexed, so it can still be purged
+	var partial *domain.PartialWriteError
+	stored := err == nil || errors.As(err, &partial)
+
+	switch {
+	case err == nil:
+		metrics.CacheWritesTotal.WithLabelValues("ok").Inc()
+		job.log.Info("successfully set item in cache")
+	case errors.Is(err, domain.ErrCacheUnavailable):
+		metrics.CacheWritesTotal.WithLabelValues("unavailable").Inc()
+		job.log.Debug("cache is unavailable, skipping write", slog.Bool("stored", stored))
+	default:
+		metrics.CacheWritesTotal.WithLabelValues("error").Inc()
+		job.log.Error("failed to set item in cache", slog.Bool("stored", stored), slog.String("error", err.Error()))
+	}
+
+	if stored {
+		w.addToIndex(ctx, job)
+	}
+}
+
+func (w *cacheWriter) addToIndex(ctx context.Context, job cacheWrite) {
+	if err := w.index.Add(ctx, job.cacheKey, job.imagePath, job.tags, time.Until(job.variant.ExpiresAt)); err != nil {
+		job.log.Error("failed to index variant", slog.String("error", err.Error()))
+	}
+}
+
+func (w *cacheWriter) drop(job cacheWrite, reason string) {
+	if job.indexOnly {
+		job.log.Debug("dropping variant tags", slog.String("reason", reason))
+		job.done()
+		return
+	}
+	metrics.CacheWritesTotal.WithLabelValues(reason).Inc()
+	job.log.Warn("dropping cache write", slog.String("reason", reason))
+	job.done()
+}
@@ -0,0 +1,144 @@
+// Package circuitbreaker stops calls to a dependency after repeated failures
+// and lets a single probe through once in a while to find out whether it
+// recovered.
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/elect0/chimera/internal/metrics"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+type Options struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting a probe through.
+	OpenTimeout time.Duration
+}
+
+// Breaker is a consecutive failure circuit breaker. Callers ask Allow before
+// calling the dependency and report the outcome with Success or Failure.
+type Breaker struct {
+	name string
+	opts Options
+
+	mu             sync.Mutex
+	state          State
+	failures       int
+	openedAt       time.Time
+	probing        bool
+	probeStartedAt time.Time
+}
+
+func New(name string, opts Options) *Breaker {
+	b := &Breaker{name: name, opts: opts}
+	metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(Closed))
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Allow reports whether a call may go through. While half-open only one probe
+// is in flight at a time, its outcome decides whether the breaker closes.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.opts.OpenTimeout {
+			return false
+		}
+		b.setState(HalfOpen)
+		b.startProbe()
+		return true
+	case HalfOpen:
+		// a probe whose outcome was never reported doesn't block the breaker forever
+		if b.probing && time.Since(b.probeStartedAt) < b.opts.OpenTimeout {
+			return false
+		}
+		b.startProbe()
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != Closed {
+		b.setState(Closed)
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == HalfOpen || b.failures >= b.opts.FailureThreshold {
+		b.open()
+	}
+}
+
+// Trip opens the breaker right away, e.g. when a dependency is already known
+// to be down at startup.
+func (b *Breaker) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open()
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) startProbe() {
+	b.probing = true
+	b.probeStartedAt = time.Now()
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	if b.state != Open {
+		b.setState(Open)
+	}
+}
+
+func (b *Breaker) setState(state State) {
+	b.state = state
+	metrics.CircuitBreakerState.WithLabelValues(b.name).Set(float64(state))
+	metrics.CircuitBreakerTransitionsTotal.WithLabelValues(b.name, state.String()).Inc()
+}
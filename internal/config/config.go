@@ -2,11 +2,204 @@ package config
 
 import (
 	"log"
+	"net/netip"
+	"net/url"
+	"runtime"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
+// S3Client holds the connection settings shared by s3 origins and the s3
+// cache tier.
+type S3Client struct {
+	Region string `mapstructure:"region"`
+	// Endpoint points the client at an S3-compatible store such as MinIO.
+	Endpoint        string `mapstructure:"endpoint"`
+	UsePathStyle    bool   `mapstructure:"use_path_style"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	SessionToken    string `mapstructure:"session_token"`
+	Profile         string `mapstructure:"profile"`
+	// ChecksumValidation is "when_supported" or "when_required".
+	ChecksumValidation string `mapstructure:"checksum_validation"`
+}
+
+// Origin is a named source of original images. Requests are routed to it by
+// an explicit origin parameter, the host of a url or the longest path prefix.
+type Origin struct {
+	Name string `mapstructure:"name"`
+	// Type is "s3", "gcs", "azure", "http" or "local".
+	Type string `mapstructure:"type"`
+	// PathPrefix routes relative paths starting with it here, an empty prefix
+	// catches every path no longer prefix matches.
+	PathPrefix  string `mapstructure:"path_prefix"`
+	StripPrefix bool   `mapstructure:"strip_prefix"`
+	// Hosts routes urls with one of these hosts here, "*.example.com" matches
+	// every subdomain. An http origin without hosts and base url accepts any url.
+	Hosts           []string      `mapstructure:"hosts"`
+	MaxSourceSizeMB int           `mapstructure:"max_source_size_mb"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+	S3              struct {
+		Bucket   string `mapstructure:"bucket"`
+		S3Client `mapstructure:",squash"`
+	} `mapstructure:"s3"`
+	GCS struct {
+		Bucket string `mapstructure:"bucket"`
+		// Endpoint points the client at an emulator such as fake-gcs-server.
+		Endpoint string `mapstructure:"endpoint"`
+		// CredentialsFile is a service account key, the default application
+		// credentials are used without it.
+		CredentialsFile string `mapstructure:"credentials_file"`
+		Anonymous       bool   `mapstructure:"anonymous"`
+	} `mapstructure:"gcs"`
+	Azure struct {
+		Container   string `mapstructure:"container"`
+		AccountName string `mapstructure:"account_name"`
+		AccountKey  string `mapstructure:"account_key"`
+		// ConnectionString replaces the account settings, e.g. for Azurite.
+		ConnectionString string `mapstructure:"connection_string"`
+		// Endpoint overrides https://<account_name>.blob.core.windows.net.
+		Endpoint string `mapstructure:"endpoint"`
+	} `mapstructure:"azure"`
+	HTTP struct {
+		BaseURL string            `mapstructure:"base_url"`
+		Headers map[string]string `mapstructure:"headers"`
+	} `mapstructure:"http"`
+	Local struct {
+		Root string `mapstructure:"root"`
+		// Symlinks is "within_root" to follow links that stay inside the root,
+		// or "deny" to reject every path going through a link.
+		Symlinks string `mapstructure:"symlinks"`
+	} `mapstructure:"local"`
+}
+
+// MaxSourceBytes is the size limit of the origin, which can only lower the
+// global limit.
+func (o Origin) MaxSourceBytes(cfg *Config) int64 {
+	limit := cfg.MaxSourceBytes()
+	if o.MaxSourceSizeMB > 0 {
+		limit = min(limit, int64(o.MaxSourceSizeMB)*1024*1024)
+	}
+	return limit
+}
+
+// RemoteFetch restricts which remote urls can be fetched, and how.
+type RemoteFetch struct {
+	MaxDownloadSizeMB int      `mapstructure:"max_download_size_mb"`
+	AllowedSchemes    []string `mapstructure:"allowed_schemes"`
+	// DenyCIDRs are blocked on top of the private and reserved ranges.
+	DenyCIDRs []string `mapstructure:"deny_cidrs"`
+	// AllowCIDRs stay reachable even inside a denied range, e.g. an internal
+	// image service.
+	AllowCIDRs   []string `mapstructure:"allow_cidrs"`
+	MaxRedirects int      `mapstructure:"max_redirects"`
+	// AllowedHosts limits remote urls to these host patterns when not empty.
+	AllowedHosts []string     `mapstructure:"allowed_hosts"`
+	DeniedHosts  []string     `mapstructure:"denied_hosts"`
+	Hosts        []HostPolicy `mapstructure:"hosts"`
+	Transport    struct {
+		DialTimeout           time.Duration `mapstructure:"dial_timeout"`
+		TLSHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout"`
+		ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+		IdleConnTimeout       time.Duration `mapstructure:"idle_conn_timeout"`
+		MaxIdleConns          int           `mapstructure:"max_idle_conns"`
+		MaxIdleConnsPerHost   int           `mapstructure:"max_idle_conns_per_host"`
+	} `mapstructure:"transport"`
+	Retry struct {
+		// MaxAttempts counts the first attempt, 1 disables retries.
+		MaxAttempts int           `mapstructure:"max_attempts"`
+		BaseDelay   time.Duration `mapstructure:"base_delay"`
+		MaxDelay    time.Duration `mapstructure:"max_delay"`
+	} `mapstructure:"retry"`
+	// CircuitBreaker is kept per host.
+	CircuitBreaker struct {
+		FailureThreshold int           `mapstructure:"failure_threshold"`
+		OpenTimeout      time.Duration `mapstructure:"open_timeout"`
+	} `mapstructure:"circuit_breaker"`
+	MaxConcurrentPerHost int `mapstructure:"max_concurrent_per_host"`
+}
+
+// HostPolicy overrides the fetch settings for the hosts matching its pattern.
+type HostPolicy struct {
+	Pattern           string            `mapstructure:"pattern"`
+	MaxDownloadSizeMB int               `mapstructure:"max_download_size_mb"`
+	Timeout           time.Duration     `mapstructure:"timeout"`
+	Headers           map[string]string `mapstructure:"headers"`
+	Auth              struct {
+		// Type is "bearer" or "basic", empty sends no credentials.
+		Type     string `mapstructure:"type"`
+		Token    string `mapstructure:"token"`
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"auth"`
+}
+
+// HostAllowed reports whether urls from host can be fetched. Denied hosts
+// always lose, and once an allowlist is set a host has to be on it or have a
+// policy of its own.
+func (r RemoteFetch) HostAllowed(host string) bool {
+	host, ok := normalizeHost(host)
+	if !ok {
+		return false
+	}
+
+	for _, pattern := range r.DeniedHosts {
+		if HostMatches(host, pattern) {
+			return false
+		}
+	}
+
+	if len(r.AllowedHosts) == 0 {
+		return true
+	}
+
+	for _, pattern := range r.AllowedHosts {
+		if HostMatches(host, pattern) {
+			return true
+		}
+	}
+	_, ok = r.Policy(host)
+	return ok
+}
+
+// Policy returns the first host policy matching host.
+func (r RemoteFetch) Policy(host string) (HostPolicy, bool) {
+	for _, policy := range r.Hosts {
+		if HostMatches(host, policy.Pattern) {
+			return policy, true
+		}
+	}
+	return HostPolicy{}, false
+}
+
+// HostMatches reports whether host matches pattern, where "*.example.com"
+// matches every subdomain of example.com. Case and the trailing dot of fully
+// qualified names are ignored, names with empty labels never match.
+func HostMatches(host, pattern string) bool {
+	host, hostOK := normalizeHost(host)
+	pattern, patternOK := normalizeHost(pattern)
+	if !hostOK || !patternOK {
+		return false
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// normalizeHost lowercases host and drops the trailing dot of a fully
+// qualified name, so "Evil.Internal." and "evil.internal" are the same host.
+func normalizeHost(host string) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || slices.Contains(strings.Split(host, "."), "") {
+		return "", false
+	}
+	return host, true
+}
+
 type Config struct {
 	HttpSever struct {
 		Port            int           `mapstructure:"port"`
@@ -19,18 +212,150 @@ type Config struct {
 		Bucket string `mapstructure:"bucket"`
 		Region string `mapstructure:"region"`
 	} `mapstructure:"s3"`
+	Origins []Origin `mapstructure:"origins"`
+	Health  struct {
+		Timeout  time.Duration `mapstructure:"timeout"`
+		CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	} `mapstructure:"health"`
 	Redis struct {
-		Address  string `mapstructure:"address"`
-		Password string `mapstructure:"password"`
-		DB       int    `mapstructure:"int"`
+		Mode             string   `mapstructure:"mode"`
+		Address          string   `mapstructure:"address"`
+		Addresses        []string `mapstructure:"addresses"`
+		MasterName       string   `mapstructure:"master_name"`
+		Username         string   `mapstructure:"username"`
+		Password         string   `mapstructure:"password"`
+		SentinelUsername string   `mapstructure:"sentinel_username"`
+		SentinelPassword string   `mapstructure:"sentinel_password"`
+		DB               int      `mapstructure:"db"`
+		TLS              struct {
+			Enabled            bool   `mapstructure:"enabled"`
+			CAFile             string `mapstructure:"ca_file"`
+			CertFile           string `mapstructure:"cert_file"`
+			KeyFile            string `mapstructure:"key_file"`
+			ServerName         string `mapstructure:"server_name"`
+			InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+		} `mapstructure:"tls"`
+		CircuitBreaker struct {
+			FailureThreshold int           `mapstructure:"failure_threshold"`
+			OpenTimeout      time.Duration `mapstructure:"open_timeout"`
+		} `mapstructure:"circuit_breaker"`
+		PoolSize     int           `mapstructure:"pool_size"`
+		MinIdleConns int           `mapstructure:"min_idle_conns"`
+		MaxRetries   int           `mapstructure:"max_retries"`
+		DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+		ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+		WriteTimeout time.Duration `mapstructure:"write_timeout"`
+		PoolTimeout  time.Duration `mapstructure:"pool_timeout"`
 	} `mapstructure:"redis"`
+	Cache struct {
+		Backend              string                   `mapstructure:"backend"`
+		TTL                  time.Duration            `mapstructure:"ttl"`
+		OriginTTL            map[string]time.Duration `mapstructure:"origin_ttl"`
+		StaleWhileRevalidate time.Duration            `mapstructure:"stale_while_revalidate"`
+		StaleIfError         time.Duration            `mapstructure:"stale_if_error"`
+		Revalidation         struct {
+			Enabled      bool          `mapstructure:"enabled"`
+			Interval     time.Duration `mapstructure:"interval"`
+			Synchronous  bool          `mapstructure:"synchronous"`
+			MaxConfirmed int           `mapstructure:"max_confirmed"`
+		} `mapstructure:"revalidation"`
+		Memory struct {
+			Enabled    bool          `mapstructure:"enabled"`
+			CapacityMB int           `mapstructure:"capacity_mb"`
+			TTL        time.Duration `mapstructure:"ttl"`
+		} `mapstructure:"memory"`
+		Writer struct {
+			Workers     int           `mapstructure:"workers"`
+			QueueSize   int           `mapstructure:"queue_size"`
+			Timeout     time.Duration `mapstructure:"timeout"`
+			Synchronous bool          `mapstructure:"synchronous"`
+		} `mapstructure:"writer"`
+		Admission struct {
+			Policy    string `mapstructure:"policy"`
+			MaxSizeKB int    `mapstructure:"max_size_kb"`
+			SecondHit struct {
+				MaxEntries int `mapstructure:"max_entries"`
+			} `mapstructure:"second_hit"`
+			TinyLFU struct {
+				Counters     int `mapstructure:"counters"`
+				MinFrequency int `mapstructure:"min_frequency"`
+			} `mapstructure:"tinylfu"`
+		} `mapstructure:"admission"`
+		Negative struct {
+			Enabled        bool          `mapstructure:"enabled"`
+			NotFoundTTL    time.Duration `mapstructure:"not_found_ttl"`
+			UnsupportedTTL time.Duration `mapstructure:"unsupported_ttl"`
+			RejectedTTL    time.Duration `mapstructure:"rejected_ttl"`
+			MaxEntries     int           `mapstructure:"max_entries"`
+		} `mapstructure:"negative"`
+		Source struct {
+			Enabled         bool          `mapstructure:"enabled"`
+			Backend         string        `mapstructure:"backend"`
+			CapacityMB      int           `mapstructure:"capacity_mb"`
+			MaxObjectMB     int           `mapstructure:"max_object_mb"`
+			TTL             time.Duration `mapstructure:"ttl"`
+			RevalidateAfter time.Duration `mapstructure:"revalidate_after"`
+			Path            string        `mapstructure:"path"`
+		} `mapstructure:"source"`
+		Disk struct {
+			Path      string        `mapstructure:"path"`
+			MaxSizeMB int           `mapstructure:"max_size_mb"`
+			TTL       time.Duration `mapstructure:"ttl"`
+			Eviction  string        `mapstructure:"eviction"`
+		} `mapstructure:"disk"`
+		S3 struct {
+			Enabled   bool   `mapstructure:"enabled"`
+			Bucket    string `mapstructure:"bucket"`
+			Prefix    string `mapstructure:"prefix"`
+			S3Client  `mapstructure:",squash"`
+			KeyLayout string        `mapstructure:"key_layout"`
+			TTL       time.Duration `mapstructure:"ttl"`
+		} `mapstructure:"s3"`
+	} `mapstructure:"cache"`
 	Security struct {
-		HMACEnabled   bool   `mapstructure:"hmac_enabled"`
-		HMACSecretKey string `mapstructure:"hmac_secret_key"`
-		RemoteFetch   struct {
-			MaxDownloadSizeMB int `mapstructure:"max_download_size_mb"`
-		} `mapstructure:"remote_fetch"`
+		HMACEnabled   bool        `mapstructure:"hmac_enabled"`
+		HMACSecretKey string      `mapstructure:"hmac_secret_key"`
+		PurgeToken    string      `mapstructure:"purge_token"`
+		RemoteFetch   RemoteFetch `mapstructure:"remote_fetch"`
 	} `mapstructure:"security"`
+	Transform struct {
+		Sizes struct {
+			Mode    string `mapstructure:"mode"`
+			Widths  []int  `mapstructure:"widths"`
+			Heights []int  `mapstructure:"heights"`
+			Step    int    `mapstructure:"step"`
+		} `mapstructure:"sizes"`
+	} `mapstructure:"transform"`
+	Coalescing struct {
+		DistributedLock struct {
+			Enabled      bool          `mapstructure:"enabled"`
+			TTL          time.Duration `mapstructure:"ttl"`
+			WaitTimeout  time.Duration `mapstructure:"wait_timeout"`
+			PollInterval time.Duration `mapstructure:"poll_interval"`
+		} `mapstructure:"distributed_lock"`
+	} `mapstructure:"coalescing"`
+	Processing struct {
+		QueueTimeout     time.Duration `mapstructure:"queue_timeout"`
+		RetryAfter       time.Duration `mapstructure:"retry_after"`
+		ExpensiveFormats []string      `mapstructure:"expensive_formats"`
+		Cheap            struct {
+			Workers   int `mapstructure:"workers"`
+			QueueSize int `mapstructure:"queue_size"`
+		} `mapstructure:"cheap"`
+		Expensive struct {
+			Workers   int `mapstructure:"workers"`
+			QueueSize int `mapstructure:"queue_size"`
+		} `mapstructure:"expensive"`
+	} `mapstructure:"processing"`
+	Limits struct {
+		MaxSourceSizeMB int   `mapstructure:"max_source_size_mb"`
+		MaxSourcePixels int64 `mapstructure:"max_source_pixels"`
+		MaxFrames       int   `mapstructure:"max_frames"`
+	} `mapstructure:"limits"`
+}
+
+func (c *Config) MaxSourceBytes() int64 {
+	return int64(c.Limits.MaxSourceSizeMB) * 1024 * 1024
 }
 
 func New() *Config {
@@ -41,10 +366,108 @@ func New() *Config {
 	viper.SetDefault("s3.bucket", "")
 	viper.SetDefault("region", "eu-central-1")
 
+	viper.SetDefault("health.timeout", "2s")
+	viper.SetDefault("health.cache_ttl", "5s")
+
+	viper.SetDefault("redis.mode", "standalone")
 	viper.SetDefault("redis.address", "localhost:6379")
+	viper.SetDefault("redis.db", 0)
+	viper.SetDefault("redis.tls.enabled", false)
+	viper.SetDefault("redis.circuit_breaker.failure_threshold", 5)
+	viper.SetDefault("redis.circuit_breaker.open_timeout", "10s")
+	viper.SetDefault("redis.pool_size", 0)
+	viper.SetDefault("redis.min_idle_conns", 0)
+	viper.SetDefault("redis.max_retries", 3)
+	viper.SetDefault("redis.dial_timeout", "5s")
+	viper.SetDefault("redis.read_timeout", "3s")
+	viper.SetDefault("redis.write_timeout", "3s")
+	viper.SetDefault("redis.pool_timeout", "4s")
+
+	viper.SetDefault("cache.backend", "redis")
+	viper.SetDefault("cache.ttl", "1h")
+	viper.SetDefault("cache.stale_while_revalidate", "0s")
+	viper.SetDefault("cache.stale_if_error", "0s")
+	viper.SetDefault("cache.revalidation.enabled", false)
+	viper.SetDefault("cache.revalidation.interval", "5m")
+	viper.SetDefault("cache.revalidation.synchronous", false)
+	viper.SetDefault("cache.revalidation.max_confirmed", 100000)
+	viper.SetDefault("cache.memory.enabled", false)
+	viper.SetDefault("cache.memory.capacity_mb", 256)
+	viper.SetDefault("cache.memory.ttl", "5m")
+	viper.SetDefault("cache.writer.workers", 8)
+	viper.SetDefault("cache.writer.queue_size", 256)
+	viper.SetDefault("cache.writer.timeout", "10s")
+	viper.SetDefault("cache.writer.synchronous", false)
+	viper.SetDefault("cache.admission.policy", "always")
+	viper.SetDefault("cache.admission.max_size_kb", 0)
+	viper.SetDefault("cache.admission.second_hit.max_entries", 100000)
+	viper.SetDefault("cache.admission.tinylfu.counters", 65536)
+	viper.SetDefault("cache.admission.tinylfu.min_frequency", 2)
+	viper.SetDefault("cache.negative.enabled", false)
+	viper.SetDefault("cache.negative.not_found_ttl", "30s")
+	viper.SetDefault("cache.negative.unsupported_ttl", "10m")
+	viper.SetDefault("cache.negative.rejected_ttl", "10m")
+	viper.SetDefault("cache.negative.max_entries", 100000)
+	viper.SetDefault("cache.source.enabled", false)
+	viper.SetDefault("cache.source.backend", "memory")
+	viper.SetDefault("cache.source.capacity_mb", 512)
+	viper.SetDefault("cache.source.max_object_mb", 20)
+	viper.SetDefault("cache.source.ttl", "1h")
+	viper.SetDefault("cache.source.revalidate_after", "1m")
+	viper.SetDefault("cache.source.path", "./data/sources")
+	viper.SetDefault("cache.disk.path", "./data/cache")
+	viper.SetDefault("cache.disk.max_size_mb", 10240)
+	viper.SetDefault("cache.disk.ttl", "24h")
+	viper.SetDefault("cache.disk.eviction", "lru")
+	viper.SetDefault("cache.s3.enabled", false)
+	viper.SetDefault("cache.s3.prefix", "variants")
+	viper.SetDefault("cache.s3.region", "eu-central-1")
+	viper.SetDefault("cache.s3.checksum_validation", "when_supported")
+	viper.SetDefault("cache.s3.key_layout", "source")
+	viper.SetDefault("cache.s3.ttl", "720h")
 
 	viper.SetDefault("security.hmac_secret_key", "")
 	viper.SetDefault("security.hmac_enabled", true)
+	viper.SetDefault("security.purge_token", "")
+	viper.SetDefault("security.remote_fetch.max_download_size_mb", 25)
+	viper.SetDefault("security.remote_fetch.allowed_schemes", []string{"https", "http"})
+	viper.SetDefault("security.remote_fetch.deny_cidrs", []string{})
+	viper.SetDefault("security.remote_fetch.allow_cidrs", []string{})
+	viper.SetDefault("security.remote_fetch.max_redirects", 5)
+	viper.SetDefault("security.remote_fetch.allowed_hosts", []string{})
+	viper.SetDefault("security.remote_fetch.denied_hosts", []string{})
+	viper.SetDefault("security.remote_fetch.transport.dial_timeout", "5s")
+	viper.SetDefault("security.remote_fetch.transport.tls_handshake_timeout", "5s")
+	viper.SetDefault("security.remote_fetch.transport.response_header_timeout", "10s")
+	viper.SetDefault("security.remote_fetch.transport.idle_conn_timeout", "90s")
+	viper.SetDefault("security.remote_fetch.transport.max_idle_conns", 100)
+	viper.SetDefault("security.remote_fetch.transport.max_idle_conns_per_host", 10)
+	viper.SetDefault("security.remote_fetch.retry.max_attempts", 3)
+	viper.SetDefault("security.remote_fetch.retry.base_delay", "100ms")
+	viper.SetDefault("security.remote_fetch.retry.max_delay", "2s")
+	viper.SetDefault("security.remote_fetch.circuit_breaker.failure_threshold", 5)
+	viper.SetDefault("security.remote_fetch.circuit_breaker.open_timeout", "30s")
+	viper.SetDefault("security.remote_fetch.max_concurrent_per_host", 16)
+
+	viper.SetDefault("transform.sizes.mode", "snap")
+	viper.SetDefault("transform.sizes.step", 0)
+
+	viper.SetDefault("coalescing.distributed_lock.enabled", false)
+	viper.SetDefault("coalescing.distributed_lock.ttl", "30s")
+	viper.SetDefault("coalescing.distributed_lock.wait_timeout", "10s")
+	viper.SetDefault("coalescing.distributed_lock.poll_interval", "100ms")
+
+	viper.SetDefault("processing.queue_timeout", "10s")
+	viper.SetDefault("processing.retry_after", "5s")
+	viper.SetDefault("processing.expensive_formats", []string{"avif"})
+	viper.SetDefault("processing.cheap.workers", runtime.NumCPU())
+	viper.SetDefault("processing.cheap.queue_size", 64)
+	viper.SetDefault("processing.expensive.workers", max(1, runtime.NumCPU()/2))
+	viper.SetDefault("processing.expensive.queue_size", 16)
+
+	viper.SetDefault("limits.max_source_size_mb", 50)
+	viper.SetDefault("limits.max_source_pixels", 100_000_000)
+	viper.SetDefault("limits.max_frames", 100)
 
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -64,9 +487,254 @@ func New() *Config {
 		log.Fatalf("unable to decode into struct, %v", err)
 	}
 
-	if cfg.S3.Bucket == "" {
-		log.Fatal("s3.bucket configuration is missing")
+	if len(cfg.Origins) == 0 {
+		cfg.Origins = legacyOrigins(&cfg)
+	}
+	validateOrigins(cfg.Origins)
+
+	// viper lowercases map keys, so origin_ttl entries are matched ignoring case
+	for name := range cfg.Cache.OriginTTL {
+		if !slices.ContainsFunc(cfg.Origins, func(origin Origin) bool { return strings.EqualFold(origin.Name, name) }) {
+			log.Fatalf("cache.origin_ttl: there is no origin named '%s'", name)
+		}
+	}
+
+	if cfg.Transform.Sizes.Mode != "snap" && cfg.Transform.Sizes.Mode != "reject" {
+		log.Fatalf("transform.sizes.mode must be either 'snap' or 'reject', got '%s'", cfg.Transform.Sizes.Mode)
+	}
+
+	if cfg.Transform.Sizes.Step < 0 {
+		log.Fatal("transform.sizes.step must not be negative")
 	}
 
+	if cfg.Cache.Backend != "redis" && cfg.Cache.Backend != "disk" {
+		log.Fatalf("cache.backend must be either 'redis' or 'disk', got '%s'", cfg.Cache.Backend)
+	}
+
+	if cfg.Cache.TTL <= 0 {
+		log.Fatal("cache.ttl must be greater than zero")
+	}
+
+	if cfg.Cache.Disk.Eviction != "lru" && cfg.Cache.Disk.Eviction != "lfu" {
+		log.Fatalf("cache.disk.eviction must be either 'lru' or 'lfu', got '%s'", cfg.Cache.Disk.Eviction)
+	}
+
+	if !cfg.Cache.Writer.Synchronous && (cfg.Cache.Writer.Workers <= 0 || cfg.Cache.Writer.QueueSize < 0) {
+		log.Fatal("cache.writer.workers must be greater than zero and cache.writer.queue_size can't be negative")
+	}
+
+	if !slices.Contains([]string{"always", "second_hit", "tinylfu"}, cfg.Cache.Admission.Policy) {
+		log.Fatalf("cache.admission.policy must be one of 'always', 'second_hit' or 'tinylfu', got '%s'", cfg.Cache.Admission.Policy)
+	}
+
+	if cfg.Cache.Admission.SecondHit.MaxEntries <= 0 || cfg.Cache.Admission.TinyLFU.Counters <= 0 {
+		log.Fatal("cache.admission.second_hit.max_entries and cache.admission.tinylfu.counters must be greater than zero")
+	}
+
+	// the sketch counters saturate at 15
+	if cfg.Cache.Admission.TinyLFU.MinFrequency < 1 || cfg.Cache.Admission.TinyLFU.MinFrequency > 15 {
+		log.Fatalf("cache.admission.tinylfu.min_frequency must be between 1 and 15, got %d", cfg.Cache.Admission.TinyLFU.MinFrequency)
+	}
+
+	if cfg.Cache.Revalidation.MaxConfirmed <= 0 {
+		log.Fatal("cache.revalidation.max_confirmed must be greater than zero")
+	}
+
+	if cfg.Cache.Negative.MaxEntries <= 0 {
+		log.Fatal("cache.negative.max_entries must be greater than zero")
+	}
+
+	if cfg.Cache.Source.Backend != "memory" && cfg.Cache.Source.Backend != "disk" {
+		log.Fatalf("cache.source.backend must be either 'memory' or 'disk', got '%s'", cfg.Cache.Source.Backend)
+	}
+
+	if cfg.Cache.Source.Enabled && (cfg.Cache.Source.CapacityMB <= 0 || cfg.Cache.Source.MaxObjectMB <= 0) {
+		log.Fatal("cache.source.capacity_mb and cache.source.max_object_mb must be greater than zero")
+	}
+
+	if cfg.Cache.Memory.Enabled && cfg.Cache.Memory.CapacityMB <= 0 {
+		log.Fatal("cache.memory.capacity_mb must be greater than zero")
+	}
+
+	if cfg.Cache.S3.Enabled && cfg.Cache.S3.Bucket == "" {
+		log.Fatal("cache.s3.bucket configuration is missing")
+	}
+
+	validateS3Client("cache.s3", &cfg.Cache.S3.S3Client)
+
+	if cfg.Cache.S3.KeyLayout != "source" && cfg.Cache.S3.KeyLayout != "hashed" {
+		log.Fatalf("cache.s3.key_layout must be either 'source' or 'hashed', got '%s'", cfg.Cache.S3.KeyLayout)
+	}
+
+	for _, scheme := range cfg.Security.RemoteFetch.AllowedSchemes {
+		if scheme != "http" && scheme != "https" {
+			log.Fatalf("security.remote_fetch.allowed_schemes can only contain 'http' and 'https', got '%s'", scheme)
+		}
+	}
+
+	for _, cidr := range slices.Concat(cfg.Security.RemoteFetch.DenyCIDRs, cfg.Security.RemoteFetch.AllowCIDRs) {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			log.Fatalf("security.remote_fetch: invalid cidr '%s': %v", cidr, err)
+		}
+	}
+
+	if cfg.Security.RemoteFetch.MaxRedirects < 0 {
+		log.Fatal("security.remote_fetch.max_redirects can't be negative")
+	}
+
+	if cfg.Security.RemoteFetch.Retry.MaxAttempts <= 0 {
+		log.Fatal("security.remote_fetch.retry.max_attempts must be greater than zero")
+	}
+
+	if cfg.Security.RemoteFetch.CircuitBreaker.FailureThreshold <= 0 {
+		log.Fatal("security.remote_fetch.circuit_breaker.failure_threshold must be greater than zero")
+	}
+
+	if cfg.Security.RemoteFetch.MaxConcurrentPerHost <= 0 {
+		log.Fatal("security.remote_fetch.max_concurrent_per_host must be greater than zero")
+	}
+
+	for _, policy := range cfg.Security.RemoteFetch.Hosts {
+		if policy.Pattern == "" {
+			log.Fatal("every entry of security.remote_fetch.hosts needs a pattern")
+		}
+		switch policy.Auth.Type {
+		case "":
+		case "bearer":
+			if policy.Auth.Token == "" {
+				log.Fatalf("security.remote_fetch.hosts '%s': auth.token is required for bearer auth", policy.Pattern)
+			}
+		case "basic":
+			if policy.Auth.Username == "" {
+				log.Fatalf("security.remote_fetch.hosts '%s': auth.username is required for basic auth", policy.Pattern)
+			}
+		default:
+			log.Fatalf("security.remote_fetch.hosts '%s': auth.type must be either 'bearer' or 'basic', got '%s'", policy.Pattern, policy.Auth.Type)
+		}
+	}
+
+	if !slices.Contains([]string{"standalone", "sentinel", "cluster"}, cfg.Redis.Mode) {
+		log.Fatalf("redis.mode must be one of 'standalone', 'sentinel' or 'cluster', got '%s'", cfg.Redis.Mode)
+	}
+
+	if cfg.Redis.Mode == "sentinel" && cfg.Redis.MasterName == "" {
+		log.Fatal("redis.master_name is required in sentinel mode")
+	}
+
+	if cfg.Redis.Mode == "cluster" && cfg.Redis.DB != 0 {
+		log.Fatal("redis.db must be 0 in cluster mode")
+	}
+
+	if cfg.Redis.CircuitBreaker.FailureThreshold <= 0 {
+		log.Fatal("redis.circuit_breaker.failure_threshold must be greater than zero")
+	}
+
+	if (cfg.Redis.TLS.CertFile == "") != (cfg.Redis.TLS.KeyFile == "") {
+		log.Fatal("redis.tls.cert_file and redis.tls.key_file must be set together")
+	}
+
+	if cfg.Coalescing.DistributedLock.Enabled && cfg.Cache.Backend != "redis" {
+		log.Fatal("coalescing.distributed_lock requires the redis cache backend")
+	}
+
+	if cfg.Processing.Cheap.Workers <= 0 || cfg.Processing.Expensive.Workers <= 0 {
+		log.Fatal("processing workers must be greater than zero")
+	}
+
+	if cfg.Processing.Cheap.QueueSize < 0 || cfg.Processing.Expensive.QueueSize < 0 {
+		log.Fatal("processing.cheap.queue_size and processing.expensive.queue_size can't be negative")
+	}
+
+	if cfg.Processing.QueueTimeout <= 0 {
+		log.Fatal("processing.queue_timeout must be greater than zero")
+	}
+
+	if cfg.Limits.MaxSourceSizeMB <= 0 || cfg.Limits.MaxSourcePixels <= 0 || cfg.Limits.MaxFrames <= 0 {
+		log.Fatal("limits must be greater than zero")
+	}
+
+	slices.Sort(cfg.Transform.Sizes.Widths)
+	slices.Sort(cfg.Transform.Sizes.Heights)
+
 	return &cfg
 }
+
+// legacyOrigins keeps configs without an origins list working: the s3 bucket,
+// if any, serves every path and any url is fetched over http.
+func legacyOrigins(cfg *Config) []Origin {
+	origins := []Origin{{Name: "http", Type: "http", Timeout: 10 * time.Second}}
+
+	if cfg.S3.Bucket != "" {
+		s3Origin := Origin{Name: "s3", Type: "s3"}
+		s3Origin.S3.Bucket = cfg.S3.Bucket
+		s3Origin.S3.Region = cfg.S3.Region
+		origins = append(origins, s3Origin)
+	}
+
+	return origins
+}
+
+func validateOrigins(origins []Origin) {
+	names := make(map[string]bool, len(origins))
+	for i, origin := range origins {
+		if origin.Name == "" {
+			log.Fatal("every entry of origins needs a name")
+		}
+		// compared ignoring case, like the origin_ttl keys referring to them
+		if names[strings.ToLower(origin.Name)] {
+			log.Fatalf("origin '%s' is defined more than once, names are compared ignoring case", origin.Name)
+		}
+		names[strings.ToLower(origin.Name)] = true
+
+		switch origin.Type {
+		case "s3":
+			if origin.S3.Bucket == "" {
+				log.Fatalf("origin '%s': s3.bucket is missing", origin.Name)
+			}
+			validateS3Client("origin '"+origin.Name+"': s3", &origins[i].S3.S3Client)
+		case "gcs":
+			if origin.GCS.Bucket == "" {
+				log.Fatalf("origin '%s': gcs.bucket is missing", origin.Name)
+			}
+			if origin.GCS.Anonymous && origin.GCS.CredentialsFile != "" {
+				log.Fatalf("origin '%s': gcs.anonymous and gcs.credentials_file can't be used together", origin.Name)
+			}
+		case "azure":
+			if origin.Azure.Container == "" {
+				log.Fatalf("origin '%s': azure.container is missing", origin.Name)
+			}
+			if origin.Azure.ConnectionString == "" && origin.Azure.AccountName == "" {
+				log.Fatalf("origin '%s': azure.account_name or azure.connection_string is required", origin.Name)
+			}
+		case "http":
+			if origin.HTTP.BaseURL != "" {
+				if u, err := url.Parse(origin.HTTP.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					log.Fatalf("origin '%s': http.base_url must be an absolute http(s) url", origin.Name)
+				}
+			}
+		case "local":
+			if origin.Local.Root == "" {
+				log.Fatalf("origin '%s': local.root is missing", origin.Name)
+			}
+			if origin.Local.Symlinks == "" {
+				origins[i].Local.Symlinks = "within_root"
+			} else if origin.Local.Symlinks != "within_root" && origin.Local.Symlinks != "deny" {
+				log.Fatalf("origin '%s': local.symlinks must be either 'within_root' or 'deny', got '%s'", origin.Name, origin.Local.Symlinks)
+			}
+		default:
+			log.Fatalf("origin '%s': type must be one of 's3', 'gcs', 'azure', 'http' or 'local', got '%s'", origin.Name, origin.Type)
+		}
+	}
+}
+
+func validateS3Client(name string, c *S3Client) {
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		log.Fatalf("%s.access_key_id and %s.secret_access_key must be set together", name, name)
+	}
+
+	if c.ChecksumValidation == "" {
+		c.ChecksumValidation = "when_supported"
+	} else if c.ChecksumValidation != "when_supported" && c.ChecksumValidation != "when_required" {
+		log.Fatalf("%s.checksum_validation must be either 'when_supported' or 'when_required', got '%s'", name, c.ChecksumValidation)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import "testing"
+
+func TestRemoteFetchHostAllowed(t *testing.T) {
+	remote := RemoteFetch{
+		AllowedHosts: []string{"images.example.com", "*.CDN.example.com"},
+		DeniedHosts:  []string{"evil.internal", "*.internal", "blocked.cdn.example.com"},
+		Hosts:        []HostPolicy{{Pattern: "partner.example.org"}},
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"images.example.com", true},
+		{"IMAGES.Example.COM", true},
+		{"images.example.com.", true},
+		{"a.cdn.example.com", true},
+		{"A.Cdn.Example.Com.", true},
+		{"cdn.example.com", false},
+		{"partner.example.org", true},
+		{"Partner.Example.Org.", true},
+		{"other.example.com", false},
+		{"evil.internal", false},
+		{"evil.internal.", false},
+		{"EVIL.INTERNAL.", false},
+		{"db.internal.", false},
+		{"blocked.cdn.example.com.", false},
+		{"Blocked.CDN.example.com", false},
+		{"images..example.com", false},
+		{".images.example.com", false},
+		{"images.example.com..", false},
+		{".", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := remote.HostAllowed(tt.host); got != tt.want {
+				t.Fatalf("HostAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteFetchHostAllowedWithoutAllowlist(t *testing.T) {
+	remote := RemoteFetch{DeniedHosts: []string{"*.internal", "metadata.google.internal"}}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"Example.com.", true},
+		{"metadata.google.internal.", false},
+		{"Metadata.Google.Internal", false},
+		{"x.internal.", false},
+		{"x..internal", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := remote.HostAllowed(tt.host); got != tt.want {
+				t.Fatalf("HostAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrOverloaded = errors.New("image processing is overloaded")
+	ErrCacheMiss  = errors.New("cache miss")
+	// ErrCacheUnavailable is returned without calling the cache while it is
+	// considered down.
+	ErrCacheUnavailable = errors.New("cache is unavailable")
+
+	ErrUnknownOrigin     = errors.New("unknown origin")
+	ErrSourceNotFound    = errors.New("source image not found")
+	ErrSourceUnsupported = errors.New("source is not a supported image")
+	ErrSourceRejected    = errors.New("source is not allowed")
+	// ErrSourceUnavailable is returned without calling an origin host while
+	// it is considered down.
+	ErrSourceUnavailable = errors.New("source origin is unavailable")
+
+	ErrSourceTooLarge      = errors.New("source image is too large")
+	ErrSourceTooManyPixels = errors.New("source image has too many pixels")
+	ErrSourceTooManyFrames = errors.New("source image has too many frames")
+)
+
+// LimitError reports which input limit a source image violated.
+type LimitError struct {
+	Err    error
+	Limit  int64
+	Actual int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %d exceeds the limit of %d", e.Err, e.Actual, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// HostError reports a remote url whose host isn't allowed to be fetched.
+type HostError struct {
+	Host string
+}
+
+func (e *HostError) Error() string {
+	return fmt.Sprintf("%s: host '%s' is not allowed", ErrSourceRejected, e.Host)
+}
+
+func (e *HostError) Unwrap() error {
+	return ErrSourceRejected
+}
+
+// PartialWriteError reports a cache write that some tiers stored and others
+// failed.
+type PartialWriteError struct {
+	Err error
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("cache write partially failed: %s", e.Err)
+}
+
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
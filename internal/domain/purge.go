@@ -0,0 +1,8 @@
+package domain
+
+// PurgeSelector picks the variants to invalidate. Exactly one field is set.
+type PurgeSelector struct {
+	Path   string `json:"path,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+}
@@ -0,0 +1,9 @@
+package domain
+
+// SourceImage is an original image as fetched from an origin. Version is an
+// opaque token, such as an ETag, that only the origin it came from interprets.
+type SourceImage struct {
+	Data        []byte
+	ContentType string
+	Version     string
+}
@@ -16,4 +16,7 @@ type TransformationOptions struct {
 	Crop       string
 	TargetType bimg.ImageType
 	Watermark  WatermarkOptions
+	// Origin names the origin to fetch the source from, overriding routing.
+	Origin string
+	Tags   []string
 }
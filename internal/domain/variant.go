@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/h2non/bimg"
+)
+
+// Variant is a rendered image together with what is needed to serve it from
+// cache: the real output type and size, which source revision it was rendered
+// from and when. ExpiresAt is when the variant may no longer be served at all,
+// stale windows included.
+type Variant struct {
+	Data          []byte
+	ImageType     bimg.ImageType
+	Width         int
+	Height        int
+	SourceVersion string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	Checksum      string
+}
+
+func NewVariant(data []byte, imageType bimg.ImageType, width, height int, sourceVersion string) *Variant {
+	return &Variant{
+		Data:          data,
+		ImageType:     imageType,
+		Width:         width,
+		Height:        height,
+		SourceVersion: sourceVersion,
+		CreatedAt:     time.Now().UTC(),
+		Checksum:      Checksum(data),
+	}
+}
+
+func (v *Variant) Age() time.Duration {
+	return time.Since(v.CreatedAt)
+}
+
+func (v *Variant) Expired() bool {
+	return !v.ExpiresAt.IsZero() && time.Now().After(v.ExpiresAt)
+}
+
+func (v *Variant) ContentType() string {
+	return "image/" + bimg.ImageTypeName(v.ImageType)
+}
+
+func (v *Variant) ETag() string {
+	return `"` + v.Checksum + `"`
+}
+
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,133 @@
+// Package health runs dependency checks for the readiness endpoint and caches
+// their results, so frequent probes don't hammer the dependencies.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/elect0/chimera/internal/metrics"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type Check struct {
+	Name string
+	// Optional dependencies are reported, but chimera stays ready without them.
+	Optional bool
+	Run      func(ctx context.Context) error
+}
+
+type Result struct {
+	Status    string    `json:"status"`
+	Optional  bool      `json:"optional,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+// Checker runs every check concurrently, each bounded by timeout, and reuses
+// the last report for ttl.
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	report    Report
+	checkedAt time.Time
+}
+
+func NewChecker(timeout, ttl time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:  checks,
+		timeout: timeout,
+		ttl:     ttl,
+	}
+}
+
+func (c *Checker) Report(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.report
+	}
+
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	for i, check := range c.checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+
+		up := 0.0
+		if result.Status == StatusUp {
+			up = 1
+		}
+		metrics.DependencyUp.WithLabelValues(check.Name).Set(up)
+
+		switch {
+		case result.Status == StatusUp:
+		case check.Optional:
+			if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		default:
+			report.Status = StatusUnavailable
+		}
+	}
+
+	c.report = report
+	c.checkedAt = time.Now()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	// the report is shared between probes, so a probe hanging up early must not
+	// fail the checks for everyone else
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+
+	result := Result{
+		Status:    StatusUp,
+		Optional:  check.Optional,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start.UTC(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+// Package imageinfo reads image dimensions and frame counts from encoded
+// headers without decoding any pixel data.
+package imageinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+var ErrUnknownFormat = errors.New("unknown image format")
+
+type Info struct {
+	Format string
+	Width  int
+	Height int
+	Frames int
+}
+
+func (i Info) Pixels() int64 {
+	return int64(i.Width) * int64(i.Height)
+}
+
+func Inspect(buf []byte) (Info, error) {
+	switch {
+	case bytes.HasPrefix(buf, []byte("GIF87a")), bytes.HasPrefix(buf, []byte("GIF89a")):
+		info, err := decodeConfig(buf)
+		if err != nil {
+			return Info{}, err
+		}
+		info.Frames = gifFrames(buf)
+		return info, nil
+	case bytes.HasPrefix(buf, []byte("\x89PNG\r\n\x1a\n")):
+		info, err := decodeConfig(buf)
+		if err != nil {
+			return Info{}, err
+		}
+		info.Frames = pngFrames(buf)
+		return info, nil
+	case bytes.HasPrefix(buf, []byte("\xff\xd8\xff")):
+		info, err := decodeConfig(buf)
+		if err != nil {
+			return Info{}, err
+		}
+		info.Frames = 1
+		return info, nil
+	case len(buf) >= 12 && string(buf[0:4]) == "RIFF" && string(buf[8:12]) == "WEBP":
+		return webpInfo(buf)
+	default:
+		return Info{}, ErrUnknownFormat
+	}
+}
+
+func decodeConfig(buf []byte) (Info, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(buf))
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Format: format, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// gifFrames walks the block structure and counts image descriptors, skipping
+// over the LZW data sub-blocks instead of decompressing them.
+func gifFrames(buf []byte) int {
+	const headerLen = 13
+	if len(buf) < headerLen {
+		return 0
+	}
+
+	pos := headerLen
+	if flags := buf[10]; flags&0x80 != 0 {
+		pos += 3 * (1 << ((flags & 0x07) + 1))
+	}
+
+	frames := 0
+	for pos < len(buf) {
+		switch buf[pos] {
+		case 0x21: // extension: introducer, label, sub-blocks
+			pos = skipSubBlocks(buf, pos+2)
+		case 0x2c: // image descriptor
+			if pos+10 > len(buf) {
+				return frames
+			}
+			flags := buf[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 * (1 << ((flags & 0x07) + 1))
+			}
+			// LZW minimum code size precedes the image data sub-blocks
+			pos = skipSubBlocks(buf, pos+1)
+			frames++
+		default: // trailer or garbage
+			return frames
+		}
+	}
+
+	return frames
+}
+
+func skipSubBlocks(buf []byte, pos int) int {
+	for pos < len(buf) {
+		size := int(buf[pos])
+		pos++
+		if size == 0 {
+			return pos
+		}
+		pos += size
+	}
+	return len(buf)
+}
+
+// pngFrames reads num_frames from the acTL chunk of animated PNGs.
+func pngFrames(buf []byte) int {
+	pos := 8
+	for pos+8 <= len(buf) {
+		length := int(binary.BigEndian.Uint32(buf[pos : pos+4]))
+		chunkType := string(buf[pos+4 : pos+8])
+
+		switch chunkType {
+		case "acTL":
+			if pos+12 <= len(buf) {
+				return int(binary.BigEndian.Uint32(buf[pos+8 : pos+12]))
+			}
+			return 1
+		case "IDAT", "IEND":
+			return 1
+		}
+
+		pos += 12 + length
+	}
+
+	return 1
+}
+
+func webpInfo(buf []byte) (Info, error) {
+	info := Info{Format: "webp"}
+	animationFrames := 0
+
+	pos := 12
+	for pos+8 <= len(buf) {
+		fourCC := string(buf[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(buf[pos+4 : pos+8]))
+		payload := buf[pos+8 : min(len(buf), pos+8+size)]
+
+		switch fourCC {
+		case "VP8X":
+			if len(payload) < 10 {
+				return Info{}, errors.New("truncated webp VP8X chunk")
+			}
+			info.Width = int(uint24(payload[4:7])) + 1
+			info.Height = int(uint24(payload[7:10])) + 1
+		case "VP8 ":
+			if info.Width == 0 {
+				if len(payload) < 10 || payload[3] != 0x9d || payload[4] != 0x01 || payload[5] != 0x2a {
+					return Info{}, errors.New("invalid webp VP8 chunk")
+				}
+				info.Width = int(binary.LittleEndian.Uint16(payload[6:8]) & 0x3fff)
+				info.Height = int(binary.LittleEndian.Uint16(payload[8:10]) & 0x3fff)
+			}
+		case "VP8L":
+			if info.Width == 0 {
+				if len(payload) < 5 || payload[0] != 0x2f {
+					return Info{}, errors.New("invalid webp VP8L chunk")
+				}
+				bits := binary.LittleEndian.Uint32(payload[1:5])
+				info.Width = int(bits&0x3fff) + 1
+				info.Height = int((bits>>14)&0x3fff) + 1
+			}
+		case "ANMF":
+			animationFrames++
+		}
+
+		pos += 8 + size + size%2
+	}
+
+	if info.Width == 0 || info.Height == 0 {
+		return Info{}, errors.New("webp image has no dimensions")
+	}
+
+	info.Frames = max(1, animationFrames)
+	return info, nil
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
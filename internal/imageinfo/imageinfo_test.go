@@ -0,0 +1,198 @@
+package imageinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t testing.TB, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t testing.TB, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t testing.TB, width, height, frames int) []byte {
+	t.Helper()
+	anim := &gif.GIF{}
+	for range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9)
+		frame.Set(0, 0, color.White)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// apng inserts an acTL chunk announcing frames right after the IHDR chunk.
+func apng(t testing.TB, frames uint32) []byte {
+	t.Helper()
+	data := encodePNG(t, 4, 3)
+
+	body := binary.BigEndian.AppendUint32([]byte("acTL"), frames)
+	body = binary.BigEndian.AppendUint32(body, 0)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)-4))
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+
+	// signature, then length, type, 13 bytes of data and crc of IHDR
+	ihdrEnd := 8 + 8 + 13 + 4
+	return append(append(append([]byte{}, data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+}
+
+// jpegSOF is a baseline JPEG header up to its SOF0 segment. The JFIF segment
+// tells the decoder the color model, so it stops at the SOF.
+func jpegSOF(width, height uint16) []byte {
+	sof := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00}
+	sof = append(sof, 0xff, 0xc0, 0x00, 0x11, 0x08)
+	sof = binary.BigEndian.AppendUint16(sof, height)
+	sof = binary.BigEndian.AppendUint16(sof, width)
+	return append(sof, 0x03, 0x01, 0x22, 0x00, 0x02, 0x11, 0x01, 0x03, 0x11, 0x01)
+}
+
+func webp(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	out := binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body)))
+	return append(out, body...)
+}
+
+func riffChunk(fourCC string, payload []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(fourCC), uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func vp8(width, height uint16) []byte {
+	payload := []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a}
+	payload = binary.LittleEndian.AppendUint16(payload, width)
+	payload = binary.LittleEndian.AppendUint16(payload, height)
+	return riffChunk("VP8 ", payload)
+}
+
+func vp8l(width, height uint32) []byte {
+	bits := (width - 1) | (height-1)<<14
+	return riffChunk("VP8L", binary.LittleEndian.AppendUint32([]byte{0x2f}, bits))
+}
+
+func vp8x(width, height uint32) []byte {
+	payload := []byte{0x02, 0, 0, 0}
+	payload = append(payload, byte(width-1), byte((width-1)>>8), byte((width-1)>>16))
+	payload = append(payload, byte(height-1), byte((height-1)>>8), byte((height-1)>>16))
+	return riffChunk("VP8X", payload)
+}
+
+func TestInspect(t *testing.T) {
+	pngData := encodePNG(t, 40, 30)
+	jpegData := encodeJPEG(t, 16, 8)
+	gifData := encodeGIF(t, 5, 7, 3)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Info
+		wantErr bool
+	}{
+		{"png", pngData, Info{Format: "png", Width: 40, Height: 30, Frames: 1}, false},
+		{"apng", apng(t, 12), Info{Format: "png", Width: 4, Height: 3, Frames: 12}, false},
+		{"png signature only", pngData[:8], Info{}, true},
+		{"png truncated ihdr", pngData[:20], Info{}, true},
+		{"jpeg", jpegData, Info{Format: "jpeg", Width: 16, Height: 8, Frames: 1}, false},
+		{"jpeg sof", jpegSOF(4000, 3000), Info{Format: "jpeg", Width: 4000, Height: 3000, Frames: 1}, false},
+		{"jpeg truncated sof", jpegSOF(4000, 3000)[:26], Info{}, true},
+		{"jpeg soi only", []byte{0xff, 0xd8, 0xff}, Info{}, true},
+		{"gif animated", gifData, Info{Format: "gif", Width: 5, Height: 7, Frames: 3}, false},
+		{"gif without trailer", gifData[:len(gifData)-1], Info{Format: "gif", Width: 5, Height: 7, Frames: 3}, false},
+		{"gif truncated descriptor", []byte("GIF89a\x05\x00\x07\x00\x00\x00\x00\x2c\x00\x00"), Info{Format: "gif", Width: 5, Height: 7, Frames: 0}, false},
+		{"gif color table past the end", []byte("GIF89a\x05\x00\x07\x00\x87\x00\x00"), Info{}, true},
+		{"gif header only", []byte("GIF89a"), Info{}, true},
+		{"webp vp8", webp(vp8(320, 240)), Info{Format: "webp", Width: 320, Height: 240, Frames: 1}, false},
+		{"webp vp8 scale bits", webp(vp8(0xc000|320, 0x4000|240)), Info{Format: "webp", Width: 320, Height: 240, Frames: 1}, false},
+		{"webp vp8 bad start code", webp(riffChunk("VP8 ", []byte{0, 0, 0, 1, 2, 3, 0, 0, 0, 0})), Info{}, true},
+		{"webp vp8 truncated", webp(riffChunk("VP8 ", []byte{0, 0, 0, 0x9d, 0x01})), Info{}, true},
+		{"webp vp8l", webp(vp8l(16383, 1)), Info{Format: "webp", Width: 16383, Height: 1, Frames: 1}, false},
+		{"webp vp8l bad signature", webp(riffChunk("VP8L", []byte{0x2e, 0, 0, 0, 0})), Info{}, true},
+		{"webp vp8x", webp(vp8x(1<<24, 2), vp8l(1, 1)), Info{Format: "webp", Width: 1 << 24, Height: 2, Frames: 1}, false},
+		{"webp vp8x animated", webp(vp8x(100, 50), riffChunk("ANMF", make([]byte, 16)), riffChunk("ANMF", make([]byte, 16))), Info{Format: "webp", Width: 100, Height: 50, Frames: 2}, false},
+		{"webp vp8x truncated", webp(riffChunk("VP8X", []byte{0, 0, 0, 0, 1})), Info{}, true},
+		{"webp chunk size past the end", webp([]byte("VP8L\xff\xff\xff\xff\x2f")), Info{}, true},
+		{"webp without image chunk", webp(riffChunk("EXIF", []byte{1, 2, 3})), Info{}, true},
+		{"riff header only", []byte("RIFF\x00\x00\x00\x00WEBP"), Info{}, true},
+		{"empty", nil, Info{}, true},
+		{"unknown", []byte("BM\x00\x00\x00\x00"), Info{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Inspect(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Inspect() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Inspect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectUnknownFormat(t *testing.T) {
+	if _, err := Inspect([]byte("not an image")); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("Inspect() error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func FuzzInspect(f *testing.F) {
+	f.Add(encodePNG(f, 3, 2))
+	f.Add(apng(f, 5))
+	f.Add(encodeJPEG(f, 2, 2))
+	f.Add(jpegSOF(10, 10))
+	f.Add(encodeGIF(f, 2, 2, 2))
+	f.Add(webp(vp8(10, 10)))
+	f.Add(webp(vp8l(10, 10)))
+	f.Add(webp(vp8x(10, 10), riffChunk("ANMF", make([]byte, 16))))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		info, err := Inspect(data)
+		if err != nil {
+			return
+		}
+		if info.Width < 0 || info.Height < 0 || info.Frames < 0 {
+			t.Fatalf("Inspect() = %+v, want non-negative values", info)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Package lru implements a least-recently-used cache bounded by the total cost
+// of its entries rather than their count.
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	key       string
+	value     V
+	cost      int64
+	expiresAt time.Time
+}
+
+type Cache[V any] struct {
+	mu       sync.Mutex
+	capacity int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+	onEvict  func(key string, value V)
+}
+
+func New[V any](capacity int64) *Cache[V] {
+	return &Cache[V]{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// OnEvict registers a callback that runs, with the lock held, whenever an
+// entry is dropped to make room or because it expired.
+func (c *Cache[V]) OnEvict(fn func(key string, value V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := el.Value.(*entry[V])
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		c.removeElement(el, true)
+		return zero, false
+	}
+
+	c.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value under key. Entries costing more than the whole capacity are
+// not stored but still drop the previous value of key, so it isn't served once
+// outdated. A zero ttl means the entry only leaves the cache when evicted.
+func (c *Cache[V]) Set(key string, value V, cost int64, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cost > c.capacity {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el, false)
+		}
+		return false
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el, false)
+	}
+
+	el := c.ll.PushFront(&entry[V]{key: key, value: value, cost: cost, expiresAt: expiresAt})
+	c.items[key] = el
+	c.size += cost
+
+	for c.size > c.capacity {
+		c.removeElement(c.ll.Back(), true)
+	}
+
+	return true
+}
+
+func (c *Cache[V]) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	c.removeElement(el, false)
+	return true
+}
+
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *Cache[V]) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache[V]) removeElement(el *list.Element, evicted bool) {
+	e := el.Value.(*entry[V])
+
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.size -= e.cost
+
+	if evicted && c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+}
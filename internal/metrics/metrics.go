@@ -36,4 +36,220 @@ var (
 			Help: "Total number of cache misses",
 		},
 	)
+
+	CacheStaleServedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_cache_stale_served_total",
+			Help: "Total number of stale variants served, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	PurgedVariantsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "chimera_purged_variants_total",
+			Help: "Total number of variants removed through the purge API",
+		},
+	)
+
+	SourceRevalidationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_source_revalidations_total",
+			Help: "Total number of source version checks for cached variants, by result",
+		},
+		[]string{"result"},
+	)
+
+	CacheTierHitsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_cache_tier_hits_total",
+			Help: "Total number of cache hits per cache tier",
+		},
+		[]string{"tier"},
+	)
+
+	CacheTierMissesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_cache_tier_misses_total",
+			Help: "Total number of cache misses per cache tier",
+		},
+		[]string{"tier"},
+	)
+
+	DiskCacheSizeBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "chimera_disk_cache_size_bytes",
+			Help: "Total size of the entries held by a disk cache",
+		},
+		[]string{"cache"},
+	)
+
+	DiskCacheEvictionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_disk_cache_evictions_total",
+			Help: "Total number of entries evicted from a disk cache",
+		},
+		[]string{"cache"},
+	)
+
+	DependencyUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "chimera_dependency_up",
+			Help: "Whether the last readiness check of a dependency succeeded",
+		},
+		[]string{"dependency"},
+	)
+
+	RemoteFetchTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_remote_fetch_total",
+			Help: "Total number of remote fetch attempts, by outcome",
+		},
+		[]string{"method", "outcome"},
+	)
+
+	RemoteFetchDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "chimera_remote_fetch_duration_seconds",
+			Help:    "Time until the response headers of a remote fetch attempt, by outcome",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "outcome"},
+	)
+
+	RemoteFetchRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_remote_fetch_retries_total",
+			Help: "Total number of retried remote fetch attempts, by the outcome that caused the retry",
+		},
+		[]string{"outcome"},
+	)
+
+	CircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "chimera_circuit_breaker_state",
+			Help: "Current state of a circuit breaker: 0 closed, 1 open, 2 half-open",
+		},
+		[]string{"name"},
+	)
+
+	CircuitBreakerTransitionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state changes, by the state entered",
+		},
+		[]string{"name", "state"},
+	)
+
+	CacheWritesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_cache_writes_total",
+			Help: "Total number of variant cache writes, by result",
+		},
+		[]string{"result"},
+	)
+
+	CacheWriteQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "chimera_cache_write_queue_depth",
+			Help: "Number of variant cache writes waiting for a writer",
+		},
+	)
+
+	CacheAdmissionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_cache_admissions_total",
+			Help: "Total number of admission decisions for rendered variants, by deciding policy and decision",
+		},
+		[]string{"policy", "decision"},
+	)
+
+	NegativeCacheHitsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_negative_cache_hits_total",
+			Help: "Total number of requests answered from a remembered source failure, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	NegativeCacheStoredTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_negative_cache_stored_total",
+			Help: "Total number of source failures remembered in the negative cache, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	SourceCacheHitsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_source_cache_hits_total",
+			Help: "Total number of source images served from the source cache, by origin",
+		},
+		[]string{"origin"},
+	)
+
+	SourceCacheMissesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_source_cache_misses_total",
+			Help: "Total number of source images fetched from the origin on a source cache miss, by origin",
+		},
+		[]string{"origin"},
+	)
+
+	SourceCacheSkippedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_source_cache_skipped_total",
+			Help: "Total number of source images too large to be kept in the source cache, by origin",
+		},
+		[]string{"origin"},
+	)
+
+	CoalescedRequestsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "chimera_coalesced_requests_total",
+			Help: "Total number of requests that joined an in-flight transformation instead of starting one",
+		},
+	)
+
+	DistributedLockTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_distributed_lock_total",
+			Help: "Total number of distributed lock attempts by result",
+		},
+		[]string{"result"},
+	)
+
+	ProcessingQueueDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "chimera_processing_queue_duration_seconds",
+			Help:    "Time spent waiting for a processing slot in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"lane"},
+	)
+
+	ProcessingDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "chimera_processing_duration_seconds",
+			Help:    "Time spent transforming an image in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"lane"},
+	)
+
+	ProcessingQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "chimera_processing_queue_depth",
+			Help: "Number of transformations waiting for a processing slot",
+		},
+		[]string{"lane"},
+	)
+
+	ProcessingRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chimera_processing_rejected_total",
+			Help: "Total number of transformations rejected because the lane was saturated",
+		},
+		[]string{"lane"},
+	)
 )
@@ -1,8 +1,14 @@
 package ports
 
-import "context"
+import (
+	"context"
+
+	"github.com/elect0/chimera/internal/domain"
+)
 
 type CacheRepository interface {
-	Get (ctx context.Context, key string) ([]byte, error)
-	Set (ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) (*domain.Variant, error)
+	Set(ctx context.Context, key string, variant *domain.Variant) error
+	// Delete removes keys and reports how many of them were stored.
+	Delete(ctx context.Context, keys ...string) (int, error)
 }
@@ -0,0 +1,10 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+type Locker interface {
+	Acquire(ctx context.Context, key string, ttl time.Duration) (unlock func(), acquired bool, err error)
+}
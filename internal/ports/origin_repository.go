@@ -1,7 +1,20 @@
 package ports
 
-import "context"
+import (
+	"context"
+
+	"github.com/elect0/chimera/internal/domain"
+)
 
 type OriginRepository interface {
-	Get (ctx context.Context, imagePath string) ([]byte, error)
+	Get(ctx context.Context, imagePath string) (*domain.SourceImage, error)
+	// Validate reports whether version is still the current version of the
+	// source. A source that no longer exists is reported as not current.
+	Validate(ctx context.Context, imagePath, version string) (bool, error)
+}
+
+// SourceInvalidator is implemented by origins keeping copies of their sources,
+// so purging a path drops the copy along with the variants.
+type SourceInvalidator interface {
+	Invalidate(ctx context.Context, imagePath string) error
 }
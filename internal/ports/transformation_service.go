@@ -7,5 +7,6 @@ import (
 )
 
 type TransformationService interface {
-	Process(ctx context.Context, opts domain.TransformationOptions, imagePath string) ([]byte, error)
+	Process(ctx context.Context, opts domain.TransformationOptions, imagePath string) (*domain.Variant, error)
+	Purge(ctx context.Context, selector domain.PurgeSelector) (int, error)
 }
@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/elect0/chimera/internal/domain"
+)
+
+// VariantIndex tracks which cached variants were rendered from which source
+// path and tags, so they can be found again when the source changes.
+type VariantIndex interface {
+	Add(ctx context.Context, key, sourcePath string, tags []string, ttl time.Duration) error
+	Lookup(ctx context.Context, selector domain.PurgeSelector) ([]string, error)
+	Remove(ctx context.Context, selector domain.PurgeSelector) error
+}